@@ -0,0 +1,67 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// evmAddress matches Ethereum-style addresses, which are case-insensitive
+var evmAddress = regexp.MustCompile(`^0[xX][0-9a-fA-F]{40}$`)
+
+// isZeroWidth reports whether r is an invisible character pages leave in addresses
+func isZeroWidth(r rune) bool {
+	switch r {
+	case '\u200b', '\u200c', '\u200d', '\u2060', '\ufeff':
+		return true
+	}
+	return false
+}
+
+// normalizeAddress strips invisible characters and surrounding whitespace
+// from address and lower-cases EVM addresses, so the same address always
+// produces the same dedup key. Other formats are case-sensitive and kept as is.
+func normalizeAddress(address string) string {
+	address = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if isZeroWidth(r) {
+			return -1
+		}
+		return r
+	}, address))
+	if evmAddress.MatchString(address) {
+		address = strings.ToLower(address)
+	}
+	return address
+}
+
+// Values of type_source recording where a report's type came from
+const (
+	typeFromImage   = "image"
+	typeFromAddress = "address"
+)
+
+// chainPatterns infer the chain from an address's format, most specific
+// first since plain base58 addresses overlap between chains. The Solana
+// pattern, any 32 to 44 base58 characters, also matches legacy Bitcoin
+// addresses of 32 to 34 characters, so Bitcoin must come before it.
+var chainPatterns = []struct {
+	chain   string
+	pattern *regexp.Regexp
+}{
+	{"Ethereum", evmAddress},
+	{"Bitcoin", regexp.MustCompile(`^(bc1[02-9ac-hj-np-z]{11,71}|[13][1-9A-HJ-NP-Za-km-z]{25,34})$`)},
+	{"Litecoin", regexp.MustCompile(`^(ltc1[02-9ac-hj-np-z]{11,71}|[LM][1-9A-HJ-NP-Za-km-z]{26,33})$`)},
+	{"Tron", regexp.MustCompile(`^T[1-9A-HJ-NP-Za-km-z]{33}$`)},
+	{"XRP", regexp.MustCompile(`^r[1-9A-HJ-NP-Za-km-z]{24,34}$`)},
+	{"Solana", regexp.MustCompile(`^[1-9A-HJ-NP-Za-km-z]{32,44}$`)},
+}
+
+// inferChain guesses the chain of a normalized address, returning "" when
+// the format isn't recognised
+func inferChain(address string) string {
+	for _, p := range chainPatterns {
+		if p.pattern.MatchString(address) {
+			return p.chain
+		}
+	}
+	return ""
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestInferChain(t *testing.T) {
+	tests := []struct {
+		address, want string
+	}{
+		{"0xde0B295669a9FD93d5F28D9Ec85E40f4cb697BAe", "Ethereum"},
+		{"bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq", "Bitcoin"},
+		{"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", "Bitcoin"},
+		{"3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", "Bitcoin"},
+		// Legacy Bitcoin lengths that the Solana pattern also matches
+		{"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNV", "Bitcoin"},
+		{"3Ai1JZ8pdJb2ksieUV8FsxSNVJCpoPi8W", "Bitcoin"},
+		{"So11111111111111111111111111111111111111112", "Solana"},
+		{"TLa2f6VPqDgRE67v1736s7bJ8Ray5wYjU7", "Tron"},
+		{"0x123", ""},
+		{"not an address", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := inferChain(tt.address); got != tt.want {
+			t.Errorf("inferChain(%q) = %q, want %q", tt.address, got, tt.want)
+		}
+	}
+}
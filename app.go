@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// App is one instance of the service: its store, its configuration and the
+// state its handlers and scraping job share. Handlers and job functions are
+// methods on it, so they can be run against any database.
+type App struct {
+	// db is the Postgres database, or nil when reports are kept in another
+	// store, in which case only the routes of storeRoutes are served
+	db    *sql.DB
+	store Store
+	cfg   Config
+
+	// webhook and stream are notified of every new report; webhook is nil
+	// when WEBHOOK_URL is unset
+	webhook *webhook
+	stream  *reportBroker
+
+	// browserErr is why the last check couldn't reach Chrome, or nil; see
+	// checkJobBrowser. While it is set no scrape is started, but the API
+	// keeps serving.
+	browserMu  sync.Mutex
+	browserErr error
+
+	// scrapeRunning is set while a scrape is in progress so the scheduled
+	// job and /admin/scrape never run two at once
+	scrapeRunning atomic.Bool
+	// jobs tracks the scheduled and manual scraping goroutines, which must
+	// stop before the store is closed
+	jobs sync.WaitGroup
+	// chainabuseTotal is the chainabuse report total shared by the jobs'
+	// scrapers, so it isn't fetched on every run
+	chainabuseTotal reportTotalCache
+
+	counts *valueCountsCache
+}
+
+func newApp(db *sql.DB, store Store, cfg Config) *App {
+	return &App{
+		db:      db,
+		store:   store,
+		cfg:     cfg,
+		webhook: newWebhook(cfg.Webhook),
+		stream:  newReportBroker(cfg.StreamMaxSubscribers),
+		counts:  newValueCountsCache(),
+	}
+}
+
+// routes returns the API's router. Streams and manual scrapes run until ctx
+// is cancelled.
+func (a *App) routes(ctx context.Context) *mux.Router {
+	if a.db == nil {
+		return a.storeRoutes(ctx)
+	}
+
+	auth := a.cfg.Auth
+	router := mux.NewRouter()
+
+	router.Handle("/reports", auth.read(a.getReports)).Methods("GET")
+	router.Handle("/reports.csv", auth.read(a.exportReportsCSV)).Methods("GET")
+	router.Handle("/reports.jsonl", auth.read(a.exportReportsJSONL)).Methods("GET")
+	router.Handle("/reports/count", auth.read(a.getReportCount)).Methods("GET")
+	router.Handle("/reports/search", auth.read(a.searchReports)).Methods("GET")
+	router.Handle("/reports/recent", auth.read(a.getRecentReports)).Methods("GET")
+	router.Handle("/reports/stats", auth.read(a.getReportStats)).Methods("GET")
+	router.Handle("/reports/by-domain", auth.read(a.getReportsByDomain)).Methods("GET")
+	router.Handle("/reports/categories", auth.read(a.getReportCategories)).Methods("GET")
+	router.Handle("/reports/types", auth.read(a.getReportTypes)).Methods("GET")
+	router.Handle("/reports/timeline", auth.read(a.getReportTimeline)).Methods("GET")
+	router.Handle("/reports/stream", auth.read(streamReports(ctx, a.stream))).Methods("GET")
+	router.Handle("/reports/{id}", auth.read(a.getReportByID)).Methods("GET")
+	router.Handle("/reports/{id}", auth.admin(a.updateReport)).Methods("PATCH")
+	router.Handle("/reports/{id}", auth.admin(a.deleteReport)).Methods("DELETE")
+	router.HandleFunc("/health", a.getHealth).Methods("GET")
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	router.HandleFunc("/openapi.json", getOpenAPI).Methods("GET")
+	router.Handle("/admin/scrape", auth.admin(a.triggerScrape(ctx))).Methods("POST")
+	router.Handle("/admin/last-run", auth.admin(a.getLastRun)).Methods("GET")
+
+	return router
+}
+
+// storeRoutes returns the router of an App without Postgres, serving what
+// every Store supports: listing and counting reports, with filters but
+// without sorting, and fetching them by id. Its /openapi.json documents only
+// those routes and parameters.
+func (a *App) storeRoutes(ctx context.Context) *mux.Router {
+	auth := a.cfg.Auth
+	router := mux.NewRouter()
+	spec := openAPISpec
+
+	router.Handle("/reports", auth.read(a.getReports)).Methods("GET")
+	router.Handle("/reports/count", auth.read(a.getReportCount)).Methods("GET")
+	router.Handle("/reports/stream", auth.read(streamReports(ctx, a.stream))).Methods("GET")
+	router.Handle("/reports/{id}", auth.read(a.getReportByID)).Methods("GET")
+	router.HandleFunc("/health", a.getHealth).Methods("GET")
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	router.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		writeOpenAPI(w, spec)
+	}).Methods("GET")
+	router.Handle("/admin/scrape", auth.admin(a.triggerScrape(ctx))).Methods("POST")
+
+	mounted, err := mountedOpenAPI(router, "sort", "order")
+	if err != nil {
+		slog.Warn("Serving the full openapi.json", "err", err)
+		return router
+	}
+	spec = mounted
+	return router
+}
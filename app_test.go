@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitForJobs(t *testing.T) {
+	app := newApp(nil, newMemoryStore(), Config{})
+	release := make(chan struct{})
+	app.jobs.Add(1)
+	go func() {
+		defer app.jobs.Done()
+		<-release
+	}()
+
+	// A job still running when the deadline passes is reported
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := app.waitForJobs(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("waitForJobs = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+	if err := app.waitForJobs(context.Background()); err != nil {
+		t.Errorf("waitForJobs = %v after the job returned", err)
+	}
+}
+
+func TestStoreRoutesOpenAPI(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	router := newApp(nil, newMemoryStore(), Config{}).routes(ctx)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/openapi.json", nil))
+	var spec struct {
+		Paths openAPIPaths `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+	var documented []string
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	// Every documented operation is served, and nothing else is
+	mounted, err := routeOperations(router)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(documented)
+	slices.Sort(mounted)
+	if !slices.Equal(documented, mounted) {
+		t.Errorf("openapi.json documents %q, want the mounted %q", documented, mounted)
+	}
+
+	// Nor does it document sorting, which the store can't do
+	for _, param := range []string{`"#/components/parameters/sort"`, `"#/components/parameters/order"`} {
+		if strings.Contains(string(spec.Paths["/reports"]["get"]), param) {
+			t.Errorf("openapi.json documents %s on /reports", param)
+		}
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/reports?sort=name", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("sorted /reports status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestTriggerScrapeChecksRemoteBrowser(t *testing.T) {
+	// Nothing listens at the remote browser's address any more
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	app := newApp(nil, newMemoryStore(), Config{Scrape: scrapeOptions{chromeRemoteURL: srv.URL}})
+
+	// A remote browser that was reachable at startup is checked again
+	rec := httptest.NewRecorder()
+	app.triggerScrape(context.Background()).ServeHTTP(rec, httptest.NewRequest("POST", "/admin/scrape", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if app.browserError() == nil {
+		t.Error("browserError = nil after a failed check")
+	}
+	if app.scrapeRunning.Load() {
+		t.Error("scrapeRunning is still set after the check failed")
+	}
+
+	// A local Chrome missing at startup isn't looked for again
+	local := newApp(nil, newMemoryStore(), Config{})
+	local.setBrowserError(errors.New("chrome not found"))
+	if err := local.checkJobBrowser(context.Background()); err == nil || err.Error() != "chrome not found" {
+		t.Errorf("checkJobBrowser = %v, want the startup error", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/chromedp"
+)
+
+// browserCheckTimeout bounds launching or connecting to the browser in a check
+const browserCheckTimeout = 30 * time.Second
+
+// parseRemoteURL checks CHROME_REMOTE_URL, which is either a DevTools
+// websocket URL or the http address of a browser's debugging port
+func parseRemoteURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid CHROME_REMOTE_URL: %v", err)
+	}
+	switch u.Scheme {
+	case "ws", "wss", "http", "https":
+	default:
+		return "", fmt.Errorf("invalid CHROME_REMOTE_URL: unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid CHROME_REMOTE_URL %q: missing host", raw)
+	}
+	return raw, nil
+}
+
+// newAllocator returns the allocator context every browser of a job is
+// launched from, or connected to when CHROME_REMOTE_URL is set
+func newAllocator(ctx context.Context, opts scrapeOptions) (context.Context, context.CancelFunc) {
+	if opts.chromeRemoteURL != "" {
+		return chromedp.NewRemoteAllocator(ctx, opts.chromeRemoteURL)
+	}
+	allocOpts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", opts.headless),
+		chromedp.Flag("disable-gpu", opts.disableGPU),
+		chromedp.UserAgent(opts.userAgent),
+	)
+	if opts.proxyServer != "" {
+		allocOpts = append(allocOpts, chromedp.ProxyServer(opts.proxyServer))
+	}
+	return chromedp.NewExecAllocator(ctx, allocOpts...)
+}
+
+// checkBrowser launches a browser the way scrapes do and closes it again, so
+// a missing or broken Chrome is reported at startup rather than by every job
+func checkBrowser(ctx context.Context, opts scrapeOptions) error {
+	ctx, cancel := context.WithTimeout(ctx, browserCheckTimeout)
+	defer cancel()
+
+	allocCtx, cancelAlloc := newAllocator(ctx, opts)
+	defer cancelAlloc()
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	defer cancelBrowser()
+	return chromedp.Run(browserCtx)
+}
+
+// checkJobBrowser reports why a job can't scrape, or nil. A local Chrome
+// missing at startup stays missing until a restart, but a remote browser may
+// come up, or go away, at any time, so with CHROME_REMOTE_URL every job
+// checks it again and the result is what /health reports.
+func (a *App) checkJobBrowser(ctx context.Context) error {
+	opts := a.cfg.Scrape
+	if opts.chromeRemoteURL == "" {
+		return a.browserError()
+	}
+	err := checkBrowser(ctx, opts)
+	switch was := a.setBrowserError(err); {
+	case err != nil && was == nil:
+		slog.Error("Remote Chrome unavailable; scraping paused", "url", opts.chromeRemoteURL, "err", err)
+	case err == nil && was != nil:
+		slog.Info("Remote Chrome available again; scraping resumed", "url", opts.chromeRemoteURL)
+	}
+	return err
+}
+
+// browserError is why the last check couldn't reach Chrome, or nil
+func (a *App) browserError() error {
+	a.browserMu.Lock()
+	defer a.browserMu.Unlock()
+	return a.browserErr
+}
+
+// setBrowserError records the result of a check, returning the previous one
+func (a *App) setBrowserError(err error) error {
+	a.browserMu.Lock()
+	defer a.browserMu.Unlock()
+	was := a.browserErr
+	a.browserErr = err
+	return was
+}
+
+// overrideUserAgent sets opts.userAgent in the tab of ctx. A remote browser
+// wasn't launched with our --user-agent flag, so its tabs need it set here;
+// local ones already have it and are left alone.
+func overrideUserAgent(opts scrapeOptions) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if opts.chromeRemoteURL == "" {
+			return nil
+		}
+		return emulation.SetUserAgentOverride(opts.userAgent).Do(ctx)
+	})
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// defaultCategoryMap maps known category variants, compared after foldText,
+// to their canonical label. CATEGORY_MAP_FILE can add to or override it.
+var defaultCategoryMap = map[string]string{
+	"phishing":            "Phishing",
+	"phishing scam":       "Phishing",
+	"sextortion":          "Sextortion",
+	"sextortion scam":     "Sextortion",
+	"rug pull":            "Rug Pull",
+	"rug pull scam":       "Rug Pull",
+	"pig butchering":      "Pig Butchering",
+	"pig butchering scam": "Pig Butchering",
+	"pigbutchering scam":  "Pig Butchering",
+	"impersonation":       "Impersonation",
+	"impersonation scam":  "Impersonation",
+	"romance":             "Romance",
+	"romance scam":        "Romance",
+	"investment":          "Investment",
+	"investment scam":     "Investment",
+	"ransomware":          "Ransomware",
+	"other":               "Other",
+	"other scam":          "Other",
+}
+
+// categoryNormalizer canonicalizes category variants through a map
+type categoryNormalizer struct {
+	labels map[string]string
+
+	mu      sync.Mutex
+	unknown map[string]bool
+}
+
+// loadCategoryNormalizer builds the category map from defaultCategoryMap and
+// the JSON object of variant to label in CATEGORY_MAP_FILE, if set
+func loadCategoryNormalizer() (*categoryNormalizer, error) {
+	n := &categoryNormalizer{labels: map[string]string{}, unknown: map[string]bool{}}
+	for variant, label := range defaultCategoryMap {
+		n.labels[foldText(variant)] = label
+	}
+
+	path := os.Getenv("CATEGORY_MAP_FILE")
+	if path == "" {
+		return n, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CATEGORY_MAP_FILE: %v", err)
+	}
+	var custom map[string]string
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("invalid CATEGORY_MAP_FILE %s: %v", path, err)
+	}
+	for variant, label := range custom {
+		n.labels[foldText(variant)] = strings.TrimSpace(label)
+	}
+	return n, nil
+}
+
+// normalize returns the canonical label of category. Categories missing from
+// the map are logged once, so the map can be extended, and kept as they are
+// apart from surrounding whitespace.
+func (n *categoryNormalizer) normalize(category string) string {
+	folded := foldText(category)
+	if label, ok := n.labels[folded]; ok {
+		return label
+	}
+
+	n.mu.Lock()
+	if !n.unknown[folded] {
+		n.unknown[folded] = true
+		slog.Warn("Unknown category; add it to CATEGORY_MAP_FILE to normalize it", "category", category)
+	}
+	n.mu.Unlock()
+	return strings.TrimSpace(category)
+}
+
+// normalizeStoredCategories applies the current map to the reports already
+// stored, which keep the category they were inserted with otherwise. The
+// fingerprint hashes the category, so it is recomputed with it. Rows whose
+// category was normalized without it still hash their raw category and are
+// updated too, or fuzzy dedup would never match them.
+func (a *App) normalizeStoredCategories(ctx context.Context) error {
+	n := a.cfg.Scrape.categories
+	fingerprint := fmt.Sprintf(fingerprintSQL, "$3")
+	var updated int64
+	for variant, label := range n.labels {
+		res, err := retryExec(ctx, a.db, `UPDATE `+a.cfg.Tables.reports+` SET category = $1, fingerprint = `+fingerprint+`
+			WHERE lower(btrim(regexp_replace(category_raw, '\s+', ' ', 'g'))) = $2
+				AND (category <> $1 OR fingerprint IS DISTINCT FROM `+fingerprint+`)`,
+			label, variant, foldText(label))
+		if err != nil {
+			return fmt.Errorf("normalizing %q: %w", variant, err)
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		updated += affected
+	}
+	slog.Info("Normalized stored categories", "updated", updated)
+	return nil
+}
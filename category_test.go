@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// sqlFingerprint hashes like fingerprintSQL, given the stored columns and the
+// folded category the UPDATE passes as $3
+func sqlFingerprint(source, foldedCategory, address string) string {
+	sum := sha256.Sum256([]byte(source + "\x1f" + foldedCategory + "\x1f" + address))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestNormalizeStoredCategoriesFingerprint(t *testing.T) {
+	app, mock := newMockApp(t)
+	app.cfg.Scrape.categories = &categoryNormalizer{
+		labels:  map[string]string{"phishing scam": "Phishing"},
+		unknown: map[string]bool{},
+	}
+
+	var foldedLabel string
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "reports" SET category = $1, fingerprint = encode(sha256(`)).
+		WithArgs("Phishing", "phishing scam", captureArg{&foldedLabel}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := app.normalizeStoredCategories(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A report stored as "Phishing Scam" before normalization, rehashed by the
+	// UPDATE, must collide with the same scam now scraped as "phishing"
+	opts := testScrapeOptions(t)
+	opts.dedupMode = dedupFuzzy
+	stored := testReport()
+	stored.Category, stored.CategoryRaw = "Phishing", "Phishing Scam"
+	stored.Fingerprint = sqlFingerprint(stored.Source, foldedLabel, stored.Address)
+
+	scraped := testReport()
+	scraped.Category = "phishing"
+	s := newMemoryStore()
+	s.add(stored)
+	inserted, err := s.Save(context.Background(), prepareBatch([]item{scraped}, opts), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inserted) != 0 {
+		t.Errorf("fuzzy save inserted %d reports, want the normalized variant to match", len(inserted))
+	}
+}
+
+// captureArg matches any argument and stores it in v
+type captureArg struct{ v *string }
+
+func (c captureArg) Match(arg driver.Value) bool {
+	s, ok := arg.(string)
+	*c.v = s
+	return ok
+}
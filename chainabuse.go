@@ -0,0 +1,503 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/chromedp/chromedp"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// chainabuseReportsURL is the first page of the Chainabuse report listing
+const chainabuseReportsURL = "https://www.chainabuse.com/reports"
+
+// reportsPerPage is the number of report cards Chainabuse shows per results page
+const reportsPerPage = 15
+
+// selectors are the CSS selectors a scraper reads report cards with. Field
+// selectors are relative to the card.
+type selectors struct {
+	results      string
+	resultsTitle string
+	card         string
+	// wait is what the visible and ready wait strategies wait for
+	wait      string
+	category  string
+	name      string
+	address   string
+	domain    string
+	timestamp string
+	typeImg   string
+}
+
+// defaultChainabuseSelectors match the Chainabuse markup at the time of writing
+var defaultChainabuseSelectors = selectors{
+	results:      ".create-ResultsSection",
+	resultsTitle: ".create-ResultsSection__results-title",
+	card:         ".create-ScamReportCard",
+	wait:         ".create-ScamReportCard",
+	category:     ".create-ScamReportCard__category-section p",
+	name:         ".create-ScamReportCard__preview-description-wrapper",
+	address:      ".create-ReportedSection__address-section .create-ResponsiveAddress__text",
+	domain:       ".create-ReportedSection__domain",
+	timestamp:    ".create-ScamReportCard__submitted-info > span:nth-child(3)",
+	typeImg:      ".create-ReportedSection__address-section img",
+}
+
+// loadSelectors overrides each of def with the <prefix>_SELECTOR_<FIELD>
+// variable when set, and checks that none ends up empty
+func loadSelectors(prefix string, def selectors) (selectors, error) {
+	sel := def
+	fields := []struct {
+		name  string
+		value *string
+	}{
+		{"RESULTS", &sel.results},
+		{"RESULTS_TITLE", &sel.resultsTitle},
+		{"CARD", &sel.card},
+		{"WAIT", &sel.wait},
+		{"CATEGORY", &sel.category},
+		{"NAME", &sel.name},
+		{"ADDRESS", &sel.address},
+		{"DOMAIN", &sel.domain},
+		{"TIMESTAMP", &sel.timestamp},
+		{"TYPE_IMG", &sel.typeImg},
+	}
+	for _, f := range fields {
+		name := prefix + "_SELECTOR_" + f.name
+		if v, ok := os.LookupEnv(name); ok {
+			*f.value = strings.TrimSpace(v)
+		}
+		if *f.value == "" {
+			return sel, fmt.Errorf("invalid %s: selector must not be empty", name)
+		}
+	}
+	return sel, nil
+}
+
+// ChainabuseScraper scrapes the public report listing of chainabuse.com
+type ChainabuseScraper struct {
+	opts scrapeOptions
+
+	// limiter spaces out navigations to the site across all workers
+	limiter *rate.Limiter
+	// robots holds the site's robots.txt rules for the current job
+	robots *robotsRules
+	// totals caches the report total across jobs, or is nil to always
+	// fetch it
+	totals *reportTotalCache
+}
+
+func newChainabuseScraper(opts scrapeOptions, totals *reportTotalCache) *ChainabuseScraper {
+	return &ChainabuseScraper{
+		opts:    opts,
+		limiter: rate.NewLimiter(rate.Limit(opts.requestRate), 1),
+		totals:  totals,
+	}
+}
+
+func (s *ChainabuseScraper) Name() string {
+	return "chainabuse"
+}
+
+func (s *ChainabuseScraper) Scrape(ctx context.Context, pages pageRange, store storeFunc, run *scrapeRun) error {
+	// Share one browser across the job; each page is scraped in its own tab
+	allocCtx, cancelAlloc := newAllocator(ctx, s.opts)
+	defer cancelAlloc()
+	browserCtx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+	if err := chromedp.Run(browserCtx); err != nil {
+		scrapeErrors.Inc()
+		return fmt.Errorf("starting browser: %w", err)
+	}
+
+	s.robots = fetchRobots(ctx, chainabuseReportsURL, s.opts.userAgent)
+	requestRate := s.robots.rateLimit(s.opts.requestRate)
+	s.limiter.SetLimit(rate.Limit(requestRate))
+	slog.Info("Rate limiting requests", "source", s.Name(), "per_second", requestRate)
+
+	totalReports, cardsPerPage, err := s.cachedTotalReports(browserCtx)
+	if err != nil {
+		scrapeErrors.Inc()
+		return fmt.Errorf("getting total reports: %w", err)
+	}
+
+	// The first page is only short of a full page when it is also the last one
+	if cardsPerPage > 0 && cardsPerPage != reportsPerPage && totalReports > cardsPerPage {
+		slog.Warn("Unexpected reports per page", "detected", cardsPerPage, "expected", reportsPerPage)
+	} else {
+		cardsPerPage = reportsPerPage
+	}
+
+	totalPages := pageCount(totalReports, cardsPerPage)
+	slog.Info("Found reports", "source", s.Name(), "reports", totalReports, "pages", totalPages)
+	if totalPages > s.opts.maxPages {
+		slog.Warn("Report total exceeds MAX_PAGES; scraping only up to the cap", "source", s.Name(), "pages", totalPages, "max_pages", s.opts.maxPages)
+		totalPages = s.opts.maxPages
+	}
+
+	end := pages.end
+	if end < 0 || end >= totalPages {
+		end = totalPages - 1
+	}
+	if pages != allPages {
+		slog.Info("Scraping page range", "start", pages.start, "end", end)
+	}
+
+	// Full scrapes stop early in incremental mode
+	var streak *duplicateStreak
+	if s.opts.incrementalPages > 0 && pages == allPages {
+		streak = newDuplicateStreak(pages.start, s.opts.incrementalPages)
+	}
+
+	// Scrape the pages with a bounded pool of workers
+	var (
+		wg          sync.WaitGroup
+		mu          sync.Mutex
+		failedPages []int
+	)
+	pageIndexes := make(chan int)
+	for w := 0; w < s.opts.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pageIndexes {
+				pageURL := fmt.Sprintf("%s?page=%d", chainabuseReportsURL, i)
+				slog.Debug("Scraping page", "page", i+1)
+				inserted, err := s.scrapePageWithRetry(browserCtx, pageURL, store, run)
+				run.pageDone(err)
+				streak.pageDone(i, inserted, err)
+				if errors.Is(err, errDisallowedByRobots) {
+					slog.Debug("Skipping page disallowed by robots.txt", "page", i+1)
+					pagesDisallowed.Inc()
+				} else if err != nil {
+					slog.Warn("Error scraping page", "page", i+1, "err", err)
+					scrapeErrors.Inc()
+					mu.Lock()
+					failedPages = append(failedPages, i+1)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := pages.start; i <= end; i++ {
+		select {
+		case pageIndexes <- i:
+		case <-streak.done():
+			break feed
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(pageIndexes)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if len(failedPages) > 0 {
+		sort.Ints(failedPages)
+		slog.Warn("Some pages failed to scrape", "failed", len(failedPages), "total", end-pages.start+1, "pages", failedPages)
+	}
+	return nil
+}
+
+// scrapePageWithRetry calls scrapePage up to opts.maxAttempts times, doubling
+// the delay between attempts starting from opts.retryDelay
+func (s *ChainabuseScraper) scrapePageWithRetry(ctx context.Context, url string, store storeFunc, run *scrapeRun) (int, error) {
+	delay := s.opts.retryDelay
+	var (
+		inserted int
+		err      error
+	)
+	for attempt := 1; attempt <= s.opts.maxAttempts; attempt++ {
+		if inserted, err = s.scrapePage(ctx, url, store, run); err == nil || errors.Is(err, errDisallowedByRobots) {
+			return inserted, err
+		}
+		if attempt == s.opts.maxAttempts {
+			break
+		}
+
+		slog.Debug("Retrying page", "url", url, "attempt", attempt, "max_attempts", s.opts.maxAttempts, "err", err, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	return 0, fmt.Errorf("giving up after %d attempts: %w", s.opts.maxAttempts, err)
+}
+
+// scrapePage scrapes url in a new tab of the browser in ctx and stores its
+// reports, returning how many of them were new. The page's cards are counted
+// in run once they are stored.
+func (s *ChainabuseScraper) scrapePage(ctx context.Context, url string, store storeFunc, run *scrapeRun) (int, error) {
+	timer := prometheus.NewTimer(pageScrapeDuration)
+	defer timer.ObserveDuration()
+
+	tabCtx, cancel := chromedp.NewContext(ctx)
+	defer cancel()
+
+	// Bound the whole navigation, including waiting for the cards to render
+	ctx, cancelTimeout := context.WithTimeout(tabCtx, s.opts.pageTimeout)
+	defer cancelTimeout()
+
+	sel := s.opts.chainabuseSelectors
+	var reports []item
+	var htmlContent string
+
+	if err := s.robots.check(url); err != nil {
+		return 0, err
+	}
+	if err := s.limiter.Wait(ctx); err != nil {
+		return 0, err
+	}
+
+	// Navigate to the page and wait for the results section to load
+	err := chromedp.Run(ctx,
+		proxyAuth(s.opts),
+		overrideUserAgent(s.opts),
+		chromedp.Navigate(url),
+		chromedp.WaitReady(sel.results),
+	)
+	if errors.Is(err, context.DeadlineExceeded) {
+		slog.Debug("Timed out loading page", "url", url, "timeout", s.opts.pageTimeout)
+		captureDebug(tabCtx, s.opts, url, "timed out loading page")
+		return 0, fmt.Errorf("timed out loading %s: %w", url, err)
+	}
+	if err != nil {
+		captureDebug(tabCtx, s.opts, url, "navigation failed")
+		return 0, fmt.Errorf("navigating to %s: %w", url, err)
+	}
+
+	// Cards render shortly after the results section; a page that shows
+	// none by then, such as one past the last, has no reports
+	cardsCtx, cancelCards := context.WithTimeout(ctx, s.opts.cardWaitTimeout)
+	err = chromedp.Run(cardsCtx, waitForCards(s.opts.chainabuseWait, sel))
+	cancelCards()
+	if err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		slog.Debug("No report cards on page", "url", url, "strategy", s.opts.chainabuseWait)
+		captureDebug(tabCtx, s.opts, url, "no report cards")
+		pagesScraped.Inc()
+		return 0, nil
+	}
+	if err != nil {
+		captureDebug(tabCtx, s.opts, url, "waiting for report cards failed")
+		return 0, fmt.Errorf("waiting for report cards on %s: %w", url, err)
+	}
+
+	if err := chromedp.Run(ctx, chromedp.OuterHTML("html", &htmlContent)); err != nil {
+		return 0, fmt.Errorf("reading %s: %w", url, err)
+	}
+
+	// Parse the HTML with goquery to extract the reports
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlContent))
+	if err != nil {
+		return 0, fmt.Errorf("loading HTML document: %w", err)
+	}
+
+	dropped := 0
+	doc.Find(sel.card).Each(func(i int, e *goquery.Selection) {
+		report := s.parseCard(e)
+		if err := checkCard(report); err != nil {
+			slog.Debug("Dropping report card", "url", url, "card", i, "err", err)
+			dropped++
+			return
+		}
+		if s.opts.storeRawHTML {
+			report.RawHTML, _ = goquery.OuterHtml(e)
+		}
+		reports = append(reports, report)
+	})
+	if len(reports) == 0 {
+		captureDebug(tabCtx, s.opts, url, "no report cards")
+	}
+
+	// A failed store leaves nothing behind, so the page can simply be retried
+	inserted, err := store(reports)
+	if err != nil {
+		return 0, fmt.Errorf("storing reports from %s: %w", url, err)
+	}
+	run.cardsDone(len(reports)+dropped, dropped)
+
+	pagesScraped.Inc()
+	slog.Debug("Visited page", "url", url)
+	return inserted, nil
+}
+
+// checkCard returns why a parsed card can't be stored. A card without a
+// category, or without both a name and an address, means the selectors no
+// longer match the site's markup.
+func checkCard(report item) error {
+	switch {
+	case strings.TrimSpace(report.Category) == "":
+		return errors.New("no category")
+	case strings.TrimSpace(report.Name) == "" && strings.TrimSpace(report.Address) == "":
+		return errors.New("no name or address")
+	}
+	return nil
+}
+
+// parseCard extracts the report shown by a report card
+func (s *ChainabuseScraper) parseCard(e *goquery.Selection) item {
+	sel := s.opts.chainabuseSelectors
+
+	Category := e.Find(sel.category).Text()
+	Name := e.Find(sel.name).Text()
+	Address := e.Find(sel.address).Text()
+	Domain := e.Find(sel.domain).Text()
+	Timestamp := e.Find(sel.timestamp).Text()
+
+	// Handle type from img alt text
+	imgAlt := ""
+	e.Find(sel.typeImg).Each(func(_ int, img *goquery.Selection) {
+		altText, exists := img.Attr("alt")
+		if exists {
+			imgAlt = altText
+		}
+	})
+
+	// Fall back to the address format when the card has no chain logo
+	chain, typeSource := "", ""
+	if words := strings.Fields(imgAlt); len(words) > 0 {
+		chain, typeSource = words[0], typeFromImage // *Bitcoin* Logo
+	} else if chain = inferChain(normalizeAddress(Address)); chain != "" {
+		typeSource = typeFromAddress
+	}
+
+	name := processNameField(Name)
+
+	report := item{
+		ID:         uuid.New(),
+		Category:   Category,
+		Name:       name,
+		Address:    Address,
+		Type:       chain,
+		TypeSource: typeSource,
+		Domain:     nullIfBlank(Domain),
+		Source:     s.Name(),
+	}
+
+	// Keep reports whose time can't be parsed, flagged and without a time
+	t, err := time.Parse(time.RFC3339, parseTime(Timestamp))
+	if err != nil {
+		slog.Warn("Error parsing report time", "timestamp", Timestamp, "err", err)
+	} else {
+		report.Timestamp = t.Format("15:04:05")
+		report.Date = t.Format(dateLayout)
+		report.ReportedAt = &t
+		report.TimeParsed = true
+	}
+
+	return report
+}
+
+// Reparse parses a report card stored by a previous scrape
+func (s *ChainabuseScraper) Reparse(html string) (item, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return item{}, fmt.Errorf("loading card HTML: %w", err)
+	}
+	card := doc.Find(s.opts.chainabuseSelectors.card).First()
+	if card.Length() == 0 {
+		return item{}, fmt.Errorf("no report card found")
+	}
+	return s.parseCard(card), nil
+}
+
+// reportTotalCache holds a source's report total between jobs. A stale total
+// only misses the oldest reports at the end of the listing until it is
+// refreshed. The zero value is an empty cache.
+type reportTotalCache struct {
+	mu           sync.Mutex
+	total        int
+	cardsPerPage int
+	fetchedAt    time.Time
+}
+
+// cachedTotalReports returns the cached results of getTotalReports while they
+// are younger than opts.totalCacheTTL, fetching them again otherwise
+func (s *ChainabuseScraper) cachedTotalReports(ctx context.Context) (int, int, error) {
+	c := s.totals
+	if c == nil {
+		return s.getTotalReports(ctx)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if age := time.Since(c.fetchedAt); age < s.opts.totalCacheTTL {
+		slog.Debug("Using cached report total", "total", c.total, "age", age)
+		return c.total, c.cardsPerPage, nil
+	}
+
+	total, cardsPerPage, err := s.getTotalReports(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	c.total = total
+	c.cardsPerPage = cardsPerPage
+	c.fetchedAt = time.Now()
+	return total, cardsPerPage, nil
+}
+
+// getTotalReports reads the total number of reports from the first results
+// page, along with the number of report cards shown on it
+func (s *ChainabuseScraper) getTotalReports(ctx context.Context) (int, int, error) {
+	ctx, cancel := chromedp.NewContext(ctx)
+	defer cancel()
+
+	ctx, cancelTimeout := context.WithTimeout(ctx, s.opts.pageTimeout)
+	defer cancelTimeout()
+
+	if err := s.robots.check(chainabuseReportsURL); err != nil {
+		return 0, 0, err
+	}
+	if err := s.limiter.Wait(ctx); err != nil {
+		return 0, 0, err
+	}
+
+	sel := s.opts.chainabuseSelectors
+	var htmlContent string
+	err := chromedp.Run(ctx,
+		proxyAuth(s.opts),
+		overrideUserAgent(s.opts),
+		chromedp.Navigate(chainabuseReportsURL),
+		chromedp.WaitVisible(sel.resultsTitle),
+		chromedp.WaitVisible(sel.card),
+		chromedp.OuterHTML("html", &htmlContent),
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlContent))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var totalReports int
+	doc.Find(sel.resultsTitle).Each(func(i int, e *goquery.Selection) {
+		text := e.Text()
+		words := strings.Fields(text)
+		if len(words) > 0 {
+			totalReports, _ = strconv.Atoi(words[0])
+		}
+	})
+
+	cardsPerPage := doc.Find(sel.card).Length()
+	return totalReports, cardsPerPage, nil
+}
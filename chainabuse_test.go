@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestScrapersAreRateLimited(t *testing.T) {
+	for _, scraper := range newApp(nil, newMemoryStore(), Config{}).scrapers(scrapeOptions{requestRate: defaultRequestRate}) {
+		s, ok := scraper.(*ChainabuseScraper)
+		if !ok {
+			continue
+		}
+		if s.limiter == nil {
+			t.Fatalf("%s scraper has no rate limiter", s.Name())
+		}
+		if err := s.limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("%s limiter: %v", s.Name(), err)
+		}
+	}
+}
+
+func TestParseCardBlankDomain(t *testing.T) {
+	const card = `<div class="create-ScamReportCard">
+		<div class="create-ScamReportCard__category-section"><p>Phishing Scam</p></div>
+		<div class="create-ScamReportCard__preview-description-wrapper">alice</div>
+		<div class="create-ReportedSection__address-section">
+			<span class="create-ResponsiveAddress__text">0xde0B295669a9FD93d5F28D9Ec85E40f4cb697BAe</span>
+		</div>
+		<div class="create-ReportedSection__domain">
+		</div>
+	</div>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(card))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := testScrapeOptions(t)
+	s := newChainabuseScraper(opts, nil)
+
+	report := prepareReport(s.parseCard(doc.Find(opts.chainabuseSelectors.card)), opts)
+	if report.Domain != nil {
+		t.Errorf("domain = %q, want nil", *report.Domain)
+	}
+	if report.Type != "Ethereum" || report.TypeSource != typeFromAddress {
+		t.Errorf("type = %q from %q, want Ethereum from the address", report.Type, report.TypeSource)
+	}
+}
+
+func TestCachedTotalReportsSharedAcrossJobs(t *testing.T) {
+	opts := testScrapeOptions(t)
+	opts.totalCacheTTL = time.Hour
+	app := newApp(nil, newMemoryStore(), Config{})
+	app.chainabuseTotal.total, app.chainabuseTotal.cardsPerPage, app.chainabuseTotal.fetchedAt = 42, reportsPerPage, time.Now()
+
+	// A later job's scraper reuses the total its App cached
+	s := app.scrapers(opts)[0].(*ChainabuseScraper)
+	total, cardsPerPage, err := s.cachedTotalReports(context.Background())
+	if err != nil || total != 42 || cardsPerPage != reportsPerPage {
+		t.Errorf("cachedTotalReports = %d, %d, %v, want 42, %d", total, cardsPerPage, err, reportsPerPage)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Config holds every setting read from the environment. It is loaded and
+// validated once at startup, before anything is started, so a bad value stops
+// the process instead of surfacing when it is first used. LOG_LEVEL and
+// LOG_FORMAT are read earlier by setupLogger, so that loading errors are
+// logged in the configured format.
+type Config struct {
+	Store storeConfig
+	// DatabaseURL, DBSchema, Tables and DB are only used by the Postgres store
+	DatabaseURL string
+	DBSchema    string
+	Tables      tableNames
+	DB          poolConfig
+	Scrape      scrapeOptions
+	Webhook     webhookConfig
+	// StreamMaxSubscribers caps the concurrent /reports/stream clients
+	StreamMaxSubscribers int
+	Auth                 apiKeyAuth
+	CORS                 corsConfig
+	// GzipMinSize is the smallest response compressed for clients accepting gzip
+	GzipMinSize int
+	ListenAddr  string
+}
+
+// poolConfig sizes the database connection pool
+type poolConfig struct {
+	maxOpenConns    int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+}
+
+// loadConfig reads the configuration from the environment, returning the
+// first invalid setting as an error
+func loadConfig() (Config, error) {
+	var cfg Config
+	var err error
+
+	if cfg.Store, err = loadStoreConfig(); err != nil {
+		return cfg, err
+	}
+	if cfg.Store.kind == storePostgres {
+		if cfg.DatabaseURL, err = databaseURL(); err != nil {
+			return cfg, err
+		}
+		if cfg.DBSchema, err = dbSchema(); err != nil {
+			return cfg, err
+		}
+		if cfg.Tables, err = loadTableNames(); err != nil {
+			return cfg, err
+		}
+		if cfg.DB, err = loadPoolConfig(); err != nil {
+			return cfg, err
+		}
+	}
+	if cfg.Scrape, err = loadScrapeOptions(); err != nil {
+		return cfg, err
+	}
+	if cfg.Webhook, err = loadWebhookConfig(); err != nil {
+		return cfg, err
+	}
+	if cfg.StreamMaxSubscribers, err = envInt("STREAM_MAX_SUBSCRIBERS", defaultMaxSubscribers); err != nil {
+		return cfg, err
+	}
+	if cfg.Auth, err = loadAPIKeyAuth(); err != nil {
+		return cfg, err
+	}
+	cfg.CORS = loadCORSConfig()
+	if cfg.GzipMinSize, err = envInt("GZIP_MIN_SIZE", defaultGzipMinSize); err != nil {
+		return cfg, err
+	}
+	if cfg.ListenAddr, err = listenAddr(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// loadPoolConfig reads DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS and
+// DB_CONN_MAX_LIFETIME
+func loadPoolConfig() (poolConfig, error) {
+	var pool poolConfig
+	var err error
+
+	if pool.maxOpenConns, err = envInt("DB_MAX_OPEN_CONNS", defaultDBMaxOpenConns); err != nil {
+		return pool, err
+	}
+	if pool.maxIdleConns, err = envInt("DB_MAX_IDLE_CONNS", defaultDBMaxIdleConns); err != nil {
+		return pool, err
+	}
+	if pool.maxIdleConns > pool.maxOpenConns {
+		return pool, fmt.Errorf("invalid DB_MAX_IDLE_CONNS %d: must not exceed DB_MAX_OPEN_CONNS %d", pool.maxIdleConns, pool.maxOpenConns)
+	}
+	if pool.connMaxLifetime, err = envDuration("DB_CONN_MAX_LIFETIME", defaultDBConnMaxLifetime); err != nil {
+		return pool, err
+	}
+	return pool, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Database operations failing with a transient error are retried this many
+// times in all, doubling the delay from dbRetryDelay, while the pool replaces
+// the broken connection
+const (
+	dbRetryAttempts = 3
+	dbRetryDelay    = 200 * time.Millisecond
+)
+
+// isTransientDBError reports whether err is a refused or reset connection,
+// or a server shutdown or restart, after which the operation may succeed on
+// a new connection. Timeouts aren't transient: one may come after a COMMIT
+// was sent, and a repeated write would then find its own rows.
+func isTransientDBError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "57P01", "57P02", "57P03": // admin_shutdown, crash_shutdown, cannot_connect_now
+			return true
+		}
+		// Class 08 is connection exceptions
+		return pqErr.Code.Class() == "08"
+	}
+
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// withDBRetry runs fn, retrying it after transient errors. fn must be safe
+// to repeat, such as a query or a transaction that rolls back on failure.
+func withDBRetry(ctx context.Context, fn func() error) error {
+	delay := dbRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt == dbRetryAttempts || !isTransientDBError(err) || ctx.Err() != nil {
+			return err
+		}
+
+		slog.Warn("Retrying after transient database error", "attempt", attempt, "max_attempts", dbRetryAttempts, "err", err, "delay", delay)
+		dbRetries.Inc()
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// retryQuery is db.QueryContext retried with withDBRetry. Only starting the
+// query is retried; an error reading the rows is returned by rows.Err.
+func retryQuery(ctx context.Context, db *sql.DB, query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := withDBRetry(ctx, func() error {
+		var err error
+		rows, err = db.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+// retryQueryRow scans the single row of query into dest, retrying with
+// withDBRetry
+func retryQueryRow(ctx context.Context, db *sql.DB, query string, args []interface{}, dest ...interface{}) error {
+	return withDBRetry(ctx, func() error {
+		return db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	})
+}
+
+// retryExec is db.ExecContext retried with withDBRetry, for statements that
+// are safe to repeat
+func retryExec(ctx context.Context, db *sql.DB, query string, args ...interface{}) (sql.Result, error) {
+	var res sql.Result
+	err := withDBRetry(ctx, func() error {
+		var err error
+		res, err = db.ExecContext(ctx, query, args...)
+		return err
+	})
+	return res, err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestIsTransientDBError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"admin shutdown", &pq.Error{Code: "57P01"}, true},
+		{"connection failure", &pq.Error{Code: "08006"}, true},
+		{"wrapped pq error", fmt.Errorf("inserting reports: %w", &pq.Error{Code: "57P01"}), true},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, true},
+		{"bad connection", driver.ErrBadConn, true},
+		{"unique violation", &pq.Error{Code: "23505"}, false},
+		{"no rows", sql.ErrNoRows, false},
+		{"timeout", &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, false},
+		{"cancelled", context.Canceled, false},
+	}
+	for _, tt := range tests {
+		if got := isTransientDBError(tt.err); got != tt.want {
+			t.Errorf("%s: isTransientDBError(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWithDBRetry(t *testing.T) {
+	transient := &pq.Error{Code: "57P01"}
+	tests := []struct {
+		name      string
+		cancelled bool
+		err       error
+		calls     int
+	}{
+		{"gives up after the last attempt", false, transient, dbRetryAttempts},
+		{"stops when the context is cancelled", true, transient, 1},
+		{"doesn't retry a permanent error", false, &pq.Error{Code: "23505"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+
+			calls := 0
+			err := withDBRetry(ctx, func() error {
+				calls++
+				return tt.err
+			})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+			if calls != tt.calls {
+				t.Errorf("fn ran %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+
+	calls := 0
+	err := withDBRetry(context.Background(), func() error {
+		if calls++; calls == 1 {
+			return transient
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("withDBRetry = %v after %d calls, want success on the second", err, calls)
+	}
+}
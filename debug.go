@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// defaultDebugDir is where DEBUG_CAPTURE writes its files unless
+// DEBUG_CAPTURE_DIR says otherwise
+const defaultDebugDir = "debug"
+
+// debugCaptureTimeout bounds taking a capture, which runs outside the page
+// timeout since that may be what failed
+const debugCaptureTimeout = 10 * time.Second
+
+// unsafeFileChars are replaced when a URL is turned into a file name
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// captureDebug saves a screenshot and the HTML of the page open in the tab
+// tabCtx belongs to, so a page that failed or showed no cards can be
+// inspected. It does nothing unless DEBUG_CAPTURE is set or once the job is
+// cancelled, and failures are only logged.
+func captureDebug(tabCtx context.Context, opts scrapeOptions, url, reason string) {
+	if !opts.debugCapture || tabCtx.Err() != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(tabCtx, debugCaptureTimeout)
+	defer cancel()
+
+	var screenshot []byte
+	var html string
+	if err := chromedp.Run(ctx,
+		chromedp.CaptureScreenshot(&screenshot),
+		chromedp.OuterHTML("html", &html),
+	); err != nil {
+		slog.Warn("Couldn't capture page for debugging", "url", url, "err", err)
+		return
+	}
+
+	if err := os.MkdirAll(opts.debugDir, 0o755); err != nil {
+		slog.Warn("Couldn't create debug directory", "dir", opts.debugDir, "err", err)
+		return
+	}
+	base := filepath.Join(opts.debugDir, time.Now().UTC().Format("20060102T150405.000")+"-"+
+		strings.Trim(unsafeFileChars.ReplaceAllString(url, "_"), "_"))
+	for name, data := range map[string][]byte{base + ".png": screenshot, base + ".html": []byte(html)} {
+		if err := os.WriteFile(name, data, 0o644); err != nil {
+			slog.Warn("Couldn't write debug capture", "file", name, "err", err)
+			return
+		}
+	}
+	slog.Info("Captured page for debugging", "url", url, "reason", reason, "files", base+".{png,html}")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Dedup modes selected by DEDUP_MODE
+const (
+	// dedupExact skips reports whose fields all match an existing report
+	dedupExact = "exact"
+	// dedupFuzzy also skips reports whose fingerprint matches an existing one
+	dedupFuzzy = "fuzzy"
+)
+
+func parseDedupMode(v string) (string, error) {
+	switch mode := strings.ToLower(v); mode {
+	case "":
+		return dedupExact, nil
+	case dedupExact, dedupFuzzy:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid DEDUP_MODE %q: must be exact or fuzzy", v)
+}
+
+// fingerprint identifies a scam independently of how it was described: it
+// hashes the source, category and normalized address, ignoring the name.
+// Reports without an address fall back to the name, or every such report in
+// a category would collide. fingerprintSQL computes the same value in SQL.
+func fingerprint(report item) string {
+	key := report.Address
+	if key == "" {
+		key = foldText(report.Name)
+	}
+	sum := sha256.Sum256([]byte(report.Source + "\x1f" + foldText(report.Category) + "\x1f" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// fingerprintSQL is fingerprint as a SQL expression over the stored columns,
+// as migration 0011 backfilled it, with the folded category given by %s
+const fingerprintSQL = `encode(sha256(convert_to(source || chr(31) || %s || chr(31)
+	|| CASE WHEN COALESCE(address, '') <> '' THEN address
+		ELSE btrim(regexp_replace(lower(COALESCE(name, '')), '\s+', ' ', 'g')) END,
+	'UTF8')), 'hex')`
+
+// foldText lower-cases s and collapses its whitespace to single spaces
+func foldText(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+// dedupKey joins the columns of the dedup index, so reports sharing a row of
+// it can be told apart before they reach the database
+func dedupKey(report item) string {
+	domain := ""
+	if report.Domain != nil {
+		domain = *report.Domain
+	}
+	return strings.Join([]string{report.Source, report.CategoryRaw, report.Name, report.Address, report.Type, domain}, "\x1f")
+}
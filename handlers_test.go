@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// newMockApp returns an App on a sqlmock database, which fails the test if
+// any expected query isn't run
+func newMockApp(t *testing.T) (*App, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	return newApp(db, &postgresStore{db: db, tables: defaultTableNames}, Config{Tables: defaultTableNames}), mock
+}
+
+var reportColumnNames = regexp.MustCompile(`,\s*`).Split(reportColumns, -1)
+
+func reportRows(reports ...item) *sqlmock.Rows {
+	rows := sqlmock.NewRows(reportColumnNames)
+	for _, r := range reports {
+		rows.AddRow(r.ID, r.Category, r.CategoryRaw, r.Name, r.Address, r.AddressRaw, r.Type, r.TypeSource, r.Domain,
+			r.Timestamp, r.Date, r.ReportedAt, r.TimeParsed, r.Source, r.InsertedAt, r.SeenCount, r.LastSeenAt)
+	}
+	return rows
+}
+
+func testReport() item {
+	reportedAt := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	return item{
+		ID:          uuid.MustParse("0b6f1c1e-6a4e-4d7e-9a53-6f2d0c5b1e01"),
+		Category:    "Phishing",
+		CategoryRaw: "Phishing",
+		Name:        "alice",
+		Address:     "0xabc",
+		AddressRaw:  "0xabc",
+		Type:        "ETH",
+		TypeSource:  typeFromAddress,
+		Timestamp:   "00:00:00",
+		Date:        "2024-09-01",
+		ReportedAt:  &reportedAt,
+		TimeParsed:  true,
+		Source:      "chainabuse",
+		InsertedAt:  reportedAt,
+		SeenCount:   1,
+	}
+}
+
+func TestGetReports(t *testing.T) {
+	app, mock := newMockApp(t)
+	report := testReport()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM "reports" WHERE type ILIKE ANY($1)`)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+reportColumns+` FROM "reports" WHERE type ILIKE ANY($1) ORDER BY `+defaultReportOrder+" LIMIT $2 OFFSET $3")).
+		WithArgs(sqlmock.AnyArg(), 2, 2).
+		WillReturnRows(reportRows(report))
+
+	rec := httptest.NewRecorder()
+	app.getReports(rec, httptest.NewRequest("GET", "/reports?type=ETH&page=2&limit=2", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("X-Total-Count"); got != "3" {
+		t.Errorf("X-Total-Count = %q, want 3", got)
+	}
+	var page reportsPage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || page.Page != 2 || page.Limit != 2 || len(page.Data) != 1 || page.Data[0].ID != report.ID {
+		t.Errorf("page = %+v", page)
+	}
+	// A short page is the last one, so there is nothing to continue from
+	if page.NextCursor != "" {
+		t.Errorf("next_cursor = %q, want none", page.NextCursor)
+	}
+}
+
+func TestGetReportsInvalidLimit(t *testing.T) {
+	app, _ := newMockApp(t)
+	rec := httptest.NewRecorder()
+	app.getReports(rec, httptest.NewRequest("GET", "/reports?limit=0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGetReportByID(t *testing.T) {
+	report := testReport()
+	query := regexp.QuoteMeta("SELECT " + reportColumns + ` FROM "reports" WHERE id = $1`)
+	tests := []struct {
+		name   string
+		id     string
+		expect func(sqlmock.Sqlmock)
+		status int
+	}{
+		{
+			name: "found",
+			id:   report.ID.String(),
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WithArgs(report.ID).WillReturnRows(reportRows(report))
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "not found",
+			id:   report.ID.String(),
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WithArgs(report.ID).WillReturnError(sql.ErrNoRows)
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "invalid id",
+			id:     "not-a-uuid",
+			expect: func(sqlmock.Sqlmock) {},
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mock := newMockApp(t)
+			tt.expect(mock)
+
+			r := mux.SetURLVars(httptest.NewRequest("GET", "/reports/"+tt.id, nil), map[string]string{"id": tt.id})
+			rec := httptest.NewRecorder()
+			app.getReportByID(rec, r)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d, body %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				var apiErr apiError
+				if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil || apiErr.Code != tt.status {
+					t.Errorf("error body = %s", rec.Body)
+				}
+				return
+			}
+			var got item
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != report.ID || got.Address != report.Address {
+				t.Errorf("report = %+v", got)
+			}
+		})
+	}
+}
+
+func TestJSONContentType(t *testing.T) {
+	tests := []struct {
+		name   string
+		write  func(http.ResponseWriter, *http.Request)
+		status int
+	}{
+		{"writeJSON", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusCreated, map[string]int{"count": 1})
+		}, http.StatusCreated},
+		{"writeError", func(w http.ResponseWriter, r *http.Request) {
+			writeError(w, r, http.StatusBadRequest, "bad request")
+		}, http.StatusBadRequest},
+		{"unencodable", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"ch": make(chan int)})
+		}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.write(rec, httptest.NewRequest("GET", "/", nil))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			if !json.Valid(rec.Body.Bytes()) {
+				t.Errorf("body %q is not JSON", rec.Body)
+			}
+		})
+	}
+}
+
+func TestGetReportsEmpty(t *testing.T) {
+	app, mock := newMockApp(t)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM "reports"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + reportColumns + ` FROM "reports" ORDER BY`)).
+		WillReturnRows(reportRows())
+
+	rec := httptest.NewRecorder()
+	app.getReports(rec, httptest.NewRequest("GET", "/reports", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), `"data":[]`) {
+		t.Errorf("body = %s, want an empty data array", rec.Body)
+	}
+}
+
+func TestSaveFuzzyRecurrence(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store := &postgresStore{db: db, tables: defaultTableNames}
+	opts := testScrapeOptions(t)
+	opts.dedupMode = dedupFuzzy
+	report := prepareReport(testReport(), opts)
+
+	// The fuzzy filter must let a row with the same dedup key through to
+	// the conflict clause, which counts the repeat in seen_count
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?s)WHERE NOT EXISTS \(SELECT 1 FROM "reports" r WHERE r\.fingerprint = v\.fingerprint\s+AND NOT \(r\.source = v\.source .*` +
+		`ON CONFLICT .* DO UPDATE\s+SET seen_count = reports\.seen_count \+ 1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "inserted_at", "?column?"}).AddRow(report.ID, time.Now(), false))
+	mock.ExpectCommit()
+
+	inserted, err := store.Save(context.Background(), []item{report}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inserted) != 0 {
+		t.Errorf("inserted %d reports, want the repeat counted as a recurrence", len(inserted))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriteJSONWithETagIsWeak(t *testing.T) {
+	handler := compressResponses(0, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSONWithETag(w, r, testReport())
+	}))
+
+	// Identity and gzip bodies differ, so they may only share a weak tag
+	plain := httptest.NewRecorder()
+	handler.ServeHTTP(plain, httptest.NewRequest("GET", "/reports/1", nil))
+	gzipped := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/reports/1", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(gzipped, r)
+
+	etag := plain.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) || gzipped.Header().Get("ETag") != etag {
+		t.Errorf("ETags = %q and %q, want the same weak tag", etag, gzipped.Header().Get("ETag"))
+	}
+	if got := gzipped.Header().Values("Vary"); len(got) != 1 || got[0] != "Accept-Encoding" {
+		t.Errorf("Vary = %q, want Accept-Encoding once", got)
+	}
+
+	r = httptest.NewRequest("GET", "/reports/1", nil)
+	r.Header.Set("If-None-Match", etag)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotModified)
+	}
+}
+
+func TestExportReports(t *testing.T) {
+	tests := []struct {
+		name        string
+		export      func(*App) http.HandlerFunc
+		contentType string
+		want        string
+	}{
+		{"csv", func(a *App) http.HandlerFunc { return a.exportReportsCSV }, "text/csv", strings.Join(csvHeader, ",") + "\n"},
+		{"jsonl", func(a *App) http.HandlerFunc { return a.exportReportsJSONL }, "application/x-ndjson", `{"id":"` + testReport().ID.String()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mock := newMockApp(t)
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT " + reportColumns + ` FROM "reports" ORDER BY`)).
+				WillReturnRows(reportRows(testReport()))
+
+			rec := httptest.NewRecorder()
+			tt.export(app)(rec, httptest.NewRequest("GET", "/reports."+tt.name, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if !strings.Contains(rec.Header().Get("Content-Disposition"), "."+tt.name+`"`) {
+				t.Errorf("Content-Disposition = %q", rec.Header().Get("Content-Disposition"))
+			}
+			if !strings.HasPrefix(rec.Body.String(), tt.want) || strings.Count(rec.Body.String(), "\n") != strings.Count(tt.want, "\n")+1 {
+				t.Errorf("body = %q, want %q and one report", rec.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateReportRollsBackOnFingerprintError(t *testing.T) {
+	app, mock := newMockApp(t)
+	report := testReport()
+	report.Name = "bob"
+
+	// A failed fingerprint update must undo the field update with it
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE "reports" SET name = $1 WHERE id = $2 RETURNING `+reportColumns)).
+		WithArgs("bob", report.ID).
+		WillReturnRows(reportRows(report))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "reports" SET fingerprint = $2 WHERE id = $1`)).
+		WithArgs(report.ID, fingerprint(report)).
+		WillReturnError(errors.New("connection lost"))
+	mock.ExpectRollback()
+
+	id := report.ID.String()
+	r := mux.SetURLVars(httptest.NewRequest("PATCH", "/reports/"+id, strings.NewReader(`{"name":"bob"}`)), map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	app.updateReport(rec, r)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d, body %s", rec.Code, http.StatusInternalServerError, rec.Body)
+	}
+}
+
+func TestDeleteReportRetried(t *testing.T) {
+	app, mock := newMockApp(t)
+	id := uuid.New()
+	deleteReport := regexp.QuoteMeta(`DELETE FROM "reports" WHERE id = $1`)
+
+	// The first attempt deleted the report before the connection dropped,
+	// so the retry finds nothing left to delete
+	mock.ExpectExec(deleteReport).WithArgs(id).WillReturnError(&pq.Error{Code: "57P01"})
+	mock.ExpectExec(deleteReport).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 0))
+	// Without a retry, nothing deleted means there was no such report
+	mock.ExpectExec(deleteReport).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		r := mux.SetURLVars(httptest.NewRequest("DELETE", "/reports/"+id.String(), nil), map[string]string{"id": id.String()})
+		rec := httptest.NewRecorder()
+		app.deleteReport(rec, r)
+		if rec.Code != want {
+			t.Errorf("status = %d, want %d, body %s", rec.Code, want, rec.Body)
+		}
+	}
+}
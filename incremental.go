@@ -0,0 +1,71 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// duplicateStreak tells an incremental scrape when to stop: sources list
+// reports newest first, so once threshold consecutive pages yield nothing new
+// the rest of the site has been seen before. Pages finish out of order, so
+// the streak only advances over pages whose predecessors have all finished.
+//
+// A nil *duplicateStreak never stops the scrape.
+type duplicateStreak struct {
+	mu        sync.Mutex
+	threshold int
+	// next is the first page whose outcome hasn't been counted yet
+	next int
+	// pending holds the outcomes of pages finished ahead of next, true when
+	// the page had no new reports
+	pending map[int]bool
+	streak  int
+	stop    chan struct{}
+	stopped bool
+}
+
+func newDuplicateStreak(start, threshold int) *duplicateStreak {
+	return &duplicateStreak{
+		threshold: threshold,
+		next:      start,
+		pending:   map[int]bool{},
+		stop:      make(chan struct{}),
+	}
+}
+
+// pageDone records the outcome of page, where inserted is how many of its
+// reports were new. A failed page breaks the streak since its reports are unknown.
+func (d *duplicateStreak) pageDone(page, inserted int, err error) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.pending[page] = err == nil && inserted == 0
+	for {
+		allSeen, ok := d.pending[d.next]
+		if !ok {
+			return
+		}
+		delete(d.pending, d.next)
+		d.next++
+		if !allSeen {
+			d.streak = 0
+			continue
+		}
+		if d.streak++; d.streak >= d.threshold && !d.stopped {
+			slog.Info("Stopping incremental scrape: pages have no new reports", "last_page", d.next, "pages", d.streak)
+			d.stopped = true
+			close(d.stop)
+		}
+	}
+}
+
+// done is closed once the streak reaches the threshold
+func (d *duplicateStreak) done() <-chan struct{} {
+	if d == nil {
+		return nil
+	}
+	return d.stop
+}
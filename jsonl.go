@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// jsonlStore appends reports to a JSON Lines file, one report per line, and
+// serves them from memory. The file is read back when the store is opened,
+// except for "-", which writes to stdout and starts out empty.
+type jsonlStore struct {
+	*memoryStore
+	out  io.Writer
+	file *os.File
+}
+
+func openJSONLStore(path string) (*jsonlStore, error) {
+	s := &jsonlStore{memoryStore: newMemoryStore(), out: os.Stdout}
+	if path == "-" {
+		return s, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var report item
+		if err := json.Unmarshal(scanner.Bytes(), &report); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("reading %s line %d: %w", path, line, err)
+		}
+		// The fingerprint isn't written out, but is derived from what is
+		report.Fingerprint = fingerprint(report)
+		s.add(report)
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	s.out, s.file = f, f
+	return s, nil
+}
+
+// Save writes the new reports with a single write, so a failure leaves at
+// most a partial last line behind
+func (s *jsonlStore) Save(ctx context.Context, reports []item, opts scrapeOptions) ([]item, error) {
+	return s.save(reports, opts, s.write)
+}
+
+func (s *jsonlStore) write(reports []item) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, report := range reports {
+		if err := enc.Encode(report); err != nil {
+			return fmt.Errorf("encoding report: %w", err)
+		}
+	}
+	if _, err := s.out.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("writing reports: %w", err)
+	}
+	return nil
+}
+
+func (s *jsonlStore) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryStore keeps reports in memory only, so they are lost when the
+// process exits. It backs STORE=memory and the JSON Lines store, and lets the
+// scraping and dedup logic run without a database. It doesn't count
+// recurrences or keep card HTML.
+type memoryStore struct {
+	mu           sync.RWMutex
+	reports      []item
+	byID         map[uuid.UUID]int
+	keys         map[string]bool
+	fingerprints map[string]bool
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		byID:         map[uuid.UUID]int{},
+		keys:         map[string]bool{},
+		fingerprints: map[string]bool{},
+	}
+}
+
+// add indexes report; s.mu must be held
+func (s *memoryStore) add(report item) {
+	s.byID[report.ID] = len(s.reports)
+	s.reports = append(s.reports, report)
+	s.keys[dedupKey(report)] = true
+	s.fingerprints[report.Fingerprint] = true
+}
+
+func (s *memoryStore) Save(ctx context.Context, reports []item, opts scrapeOptions) ([]item, error) {
+	return s.save(reports, opts, nil)
+}
+
+// save stores the reports that aren't stored yet. When write is set it is
+// first passed the new reports, and nothing is stored if it fails.
+func (s *memoryStore) save(reports []item, opts scrapeOptions, write func([]item) error) ([]item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	var inserted []item
+	for _, report := range reports {
+		if s.keys[dedupKey(report)] || (opts.dedupMode == dedupFuzzy && s.fingerprints[report.Fingerprint]) {
+			continue
+		}
+		report.InsertedAt = now
+		report.SeenCount = 1
+		report.LastSeenAt = report.ReportedAt
+		inserted = append(inserted, report)
+	}
+	reportsSkipped.Add(float64(len(reports) - len(inserted)))
+	if len(inserted) == 0 {
+		return nil, nil
+	}
+
+	if write != nil {
+		if err := write(inserted); err != nil {
+			return nil, err
+		}
+	}
+	for _, report := range inserted {
+		s.add(report)
+	}
+	return inserted, nil
+}
+
+func (s *memoryStore) Exists(ctx context.Context, report item) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys[dedupKey(report)], nil
+}
+
+// List sorts the reports like defaultReportOrder: newest first, those
+// without a time last, ties broken by id
+func (s *memoryStore) List(ctx context.Context, query reportQuery, page, limit int) (reportsPage, error) {
+	if !query.defaultOrder() {
+		return reportsPage{}, errUnsupportedQuery
+	}
+	reports := s.matching(query)
+	sort.Slice(reports, func(i, j int) bool {
+		return defaultOrderLess(reports[i], reports[j])
+	})
+
+	result := reportsPage{Total: len(reports), Page: page, Limit: limit, Data: []item{}}
+	offset := (page - 1) * limit
+	if c := query.after; c != nil {
+		offset = sort.Search(len(reports), func(i int) bool {
+			return defaultOrderLess(item{ReportedAt: c.ReportedAt, ID: c.ID}, reports[i])
+		})
+	}
+	if offset < len(reports) {
+		result.Data = reports[offset:min(offset+limit, len(reports))]
+	}
+	return result, nil
+}
+
+// defaultOrderLess reports whether a comes before b in defaultReportOrder
+func defaultOrderLess(a, b item) bool {
+	at, bt := a.ReportedAt, b.ReportedAt
+	switch {
+	case at == nil && bt == nil, at != nil && bt != nil && at.Equal(*bt):
+		return a.ID.String() < b.ID.String()
+	case at == nil || bt == nil:
+		return bt == nil
+	}
+	return at.After(*bt)
+}
+
+func (s *memoryStore) Count(ctx context.Context, query reportQuery) (int, error) {
+	return len(s.matching(query)), nil
+}
+
+// matching returns a copy of the reports matching query
+func (s *memoryStore) matching(query reportQuery) []item {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var reports []item
+	for _, report := range s.reports {
+		if query.matches(report) {
+			reports = append(reports, report)
+		}
+	}
+	return reports
+}
+
+func (s *memoryStore) Get(ctx context.Context, id uuid.UUID) (item, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i, ok := s.byID[id]
+	if !ok {
+		return item{}, errReportNotFound
+	}
+	return s.reports[i], nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMemoryStoreListEmpty(t *testing.T) {
+	s := newMemoryStore()
+	// Past the last page is as empty as an empty store
+	s.add(testReport())
+	for _, page := range []int{2, 3} {
+		result, err := s.List(context.Background(), reportQuery{}, page, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := json.Marshal(result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(body), `"data":[]`) {
+			t.Errorf("page %d encodes as %s, want an empty data array", page, body)
+		}
+	}
+
+	result, err := newMemoryStore().List(context.Background(), reportQuery{}, 1, defaultPageLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(result)
+	if !strings.Contains(string(body), `"data":[]`) {
+		t.Errorf("empty store encodes as %s, want an empty data array", body)
+	}
+}
+
+func TestMemoryStoreDedup(t *testing.T) {
+	ctx := context.Background()
+	opts := testScrapeOptions(t)
+	s := newMemoryStore()
+
+	report := testReport()
+	inserted, err := s.Save(ctx, prepareBatch([]item{report}, opts), opts)
+	if err != nil || len(inserted) != 1 {
+		t.Fatalf("first save inserted %d reports, err %v; want 1", len(inserted), err)
+	}
+	// The same report scraped again is already stored
+	again := report
+	again.ID = uuid.New()
+	inserted, err = s.Save(ctx, prepareBatch([]item{again}, opts), opts)
+	if err != nil || len(inserted) != 0 {
+		t.Fatalf("second save inserted %d reports, err %v; want 0", len(inserted), err)
+	}
+	if result, _ := s.List(ctx, reportQuery{}, 1, defaultPageLimit); result.Total != 1 {
+		t.Errorf("total = %d, want 1", result.Total)
+	}
+}
+
+func TestDedupFuzzy(t *testing.T) {
+	ctx := context.Background()
+	exact := testScrapeOptions(t)
+	fuzzy := exact
+	fuzzy.dedupMode = dedupFuzzy
+
+	// A reworded name keeps the fingerprint but changes the dedup key
+	a := testReport()
+	b := a
+	b.ID, b.Name = uuid.New(), "Alice, again"
+
+	if got := len(prepareBatch([]item{a, b}, exact)); got != 2 {
+		t.Errorf("exact prepareBatch kept %d reports, want 2", got)
+	}
+	if got := len(prepareBatch([]item{a, b}, fuzzy)); got != 1 {
+		t.Errorf("fuzzy prepareBatch kept %d reports, want 1", got)
+	}
+
+	// Across batches it is Save that drops the repeat
+	s := newMemoryStore()
+	if _, err := s.Save(ctx, prepareBatch([]item{a}, fuzzy), fuzzy); err != nil {
+		t.Fatal(err)
+	}
+	inserted, err := s.Save(ctx, prepareBatch([]item{b}, fuzzy), fuzzy)
+	if err != nil || len(inserted) != 0 {
+		t.Errorf("fuzzy save inserted %d reports, err %v; want 0", len(inserted), err)
+	}
+	inserted, err = s.Save(ctx, prepareBatch([]item{b}, exact), exact)
+	if err != nil || len(inserted) != 1 {
+		t.Errorf("exact save inserted %d reports, err %v; want 1", len(inserted), err)
+	}
+}
+
+func TestMemoryStoreListFilters(t *testing.T) {
+	ctx := context.Background()
+	s := newMemoryStore()
+	report := testReport()
+	untyped := report
+	untyped.ID, untyped.Name, untyped.Type = uuid.New(), "bob", ""
+	s.add(report)
+	s.add(untyped)
+
+	tests := []struct {
+		name  string
+		query reportQuery
+		want  int
+	}{
+		{"none", reportQuery{}, 2},
+		{"category ignores case", reportQuery{categories: []string{"phishing"}}, 2},
+		{"type", reportQuery{types: []string{"eth"}}, 1},
+		{"unknown type", reportQuery{unknownType: true}, 1},
+		{"type or unknown", reportQuery{types: []string{"ETH"}, unknownType: true}, 2},
+		{"source", reportQuery{sources: []string{"other"}}, 0},
+		{"before", reportQuery{to: *report.ReportedAt}, 0},
+	}
+	for _, tt := range tests {
+		result, err := s.List(ctx, tt.query, 1, defaultPageLimit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Total != tt.want || len(result.Data) != tt.want {
+			t.Errorf("%s: listed %d of %d reports, want %d", tt.name, len(result.Data), result.Total, tt.want)
+		}
+	}
+}
+
+func TestMemoryStoreListAfter(t *testing.T) {
+	ctx := context.Background()
+	s := newMemoryStore()
+	for i := 0; i < 3; i++ {
+		report := testReport()
+		report.ID = uuid.New()
+		s.add(report)
+	}
+	untimed := testReport()
+	untimed.ID, untimed.ReportedAt = uuid.New(), nil
+	s.add(untimed)
+
+	// Following the cursor visits every report once, in the same order
+	all, err := s.List(ctx, reportQuery{}, 1, defaultPageLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var query reportQuery
+	for i, want := range all.Data {
+		page, err := s.List(ctx, query, 1, 1)
+		if err != nil || len(page.Data) != 1 || page.Data[0].ID != want.ID {
+			t.Fatalf("page %d = %+v, %v, want %s", i+1, page.Data, err, want.ID)
+		}
+		query.after = &reportCursor{ReportedAt: want.ReportedAt, ID: want.ID}
+	}
+	if page, _ := s.List(ctx, query, 1, 1); len(page.Data) != 0 {
+		t.Errorf("page past the last = %+v, want none", page.Data)
+	}
+
+	if _, err := s.List(ctx, reportQuery{orderBy: "name ASC NULLS LAST, id"}, 1, 1); !errors.Is(err, errUnsupportedQuery) {
+		t.Errorf("sorted List = %v, want errUnsupportedQuery", err)
+	}
+}
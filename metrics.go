@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics for the scraping job, served at /metrics
+var (
+	pagesScraped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "scraper_pages_scraped_total",
+		Help: "Number of report pages scraped successfully.",
+	})
+	cardsParsed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "scraper_cards_parsed_total",
+		Help: "Number of report cards read from stored pages.",
+	})
+	cardsDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "scraper_cards_dropped_total",
+		Help: "Number of report cards dropped because they couldn't be parsed.",
+	})
+	reportsInserted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "scraper_reports_inserted_total",
+		Help: "Number of new reports inserted into the database.",
+	})
+	reportsSkipped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "scraper_reports_skipped_total",
+		Help: "Number of scraped reports skipped as duplicates.",
+	})
+	reportsRecurred = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "scraper_reports_recurred_total",
+		Help: "Number of known reports seen again and counted as recurrences.",
+	})
+	pagesDisallowed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "scraper_pages_disallowed_total",
+		Help: "Number of report pages skipped because robots.txt disallows them.",
+	})
+	scrapeErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "scraper_errors_total",
+		Help: "Number of failed page scrapes and job runs.",
+	})
+	dbRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "scraper_db_retries_total",
+		Help: "Number of database operations retried after a transient error.",
+	})
+	pageScrapeDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "scraper_page_scrape_duration_seconds",
+		Help:    "Time taken to scrape a single report page.",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 8),
+	})
+)
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type contextKey int
+
+const requestIDKey contextKey = iota
+
+// requestIDFrom returns the id logRequests assigned to the request, if any
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController reach the writer's Flush
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// logRequests logs one line per request with its method, path,
+// status and latency. Each request gets an id, taken from X-Request-ID when
+// the client sends one, which is echoed back and stored in the context.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get("X-Request-ID")
+		if id == "" {
+			id = uuid.NewString()
+		}
+		w.Header().Set("X-Request-ID", id)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+
+		slog.Info("Request", "request_id", id, "method", r.Method, "path", r.URL.Path,
+			"status", rec.status, "latency", time.Since(start))
+	})
+}
+
+// Defaults for the CORS headers; origins have no default outside development
+const (
+	defaultCORSMethods = "GET, POST, PATCH, DELETE"
+	defaultCORSHeaders = "Content-Type, X-Request-ID, X-API-Key, If-None-Match"
+)
+
+// corsConfig is the cross-origin policy applied by allowCORS
+type corsConfig struct {
+	origins []string
+	methods string
+	headers string
+}
+
+// loadCORSConfig reads CORS_ALLOWED_ORIGINS, CORS_ALLOWED_METHODS and
+// CORS_ALLOWED_HEADERS. Origins are a comma-separated list, or * to allow any
+// origin, which is the default only in development.
+func loadCORSConfig() corsConfig {
+	cfg := corsConfig{
+		methods: os.Getenv("CORS_ALLOWED_METHODS"),
+		headers: os.Getenv("CORS_ALLOWED_HEADERS"),
+	}
+	if cfg.methods == "" {
+		cfg.methods = defaultCORSMethods
+	}
+	if cfg.headers == "" {
+		cfg.headers = defaultCORSHeaders
+	}
+
+	origins := os.Getenv("CORS_ALLOWED_ORIGINS")
+	if origins == "" && isDevMode() {
+		origins = "*"
+	}
+	for _, origin := range strings.Split(origins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.origins = append(cfg.origins, origin)
+		}
+	}
+	return cfg
+}
+
+// allowCORS adds CORS headers for allowed origins and answers preflight
+// requests itself, since routes are only registered for their own methods
+func allowCORS(cfg corsConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !(slices.Contains(cfg.origins, "*") || slices.Contains(cfg.origins, origin)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Expose-Headers", "X-Request-ID, ETag, X-Total-Count")
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", cfg.methods)
+			h.Set("Access-Control-Allow-Headers", cfg.headers)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiKeyAuth guards routes behind the X-API-Key header
+type apiKeyAuth struct {
+	key          string
+	protectReads bool
+}
+
+// loadAPIKeyAuth reads the key from API_KEY. Admin routes are always
+// protected and reject every request while no key is set; read routes are
+// protected too when PROTECT_READS is true.
+func loadAPIKeyAuth() (apiKeyAuth, error) {
+	auth := apiKeyAuth{key: os.Getenv("API_KEY")}
+	var err error
+	if auth.protectReads, err = envBool("PROTECT_READS", false); err != nil {
+		return auth, err
+	}
+	if auth.key == "" {
+		slog.Warn("API_KEY is not set; admin endpoints are disabled")
+	}
+	return auth, nil
+}
+
+// admin requires the API key for next
+func (a apiKeyAuth) admin(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := r.Header.Get("X-API-Key")
+		if a.key == "" || subtle.ConstantTimeCompare([]byte(got), []byte(a.key)) != 1 {
+			writeError(w, r, http.StatusUnauthorized, "Missing or invalid API key")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// read requires the API key for next only when reads are protected
+func (a apiKeyAuth) read(next http.HandlerFunc) http.Handler {
+	if !a.protectReads {
+		return next
+	}
+	return a.admin(next)
+}
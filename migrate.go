@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// Migrations are numbered SQL files applied in order, each exactly once. The
+// first ones predate schema_migrations, so they must stay idempotent for
+// databases created by the old inline schema.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID serializes migrations across instances starting together
+const migrationLockID = 7243051
+
+// migrate applies every migration not yet recorded in the migrations table
+// of tables, with the table names rewritten for them
+func migrate(ctx context.Context, db *sql.DB, tables tableNames) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+tables.migrations+` (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", tables.migrations, err)
+	}
+
+	// ReadDir returns the files sorted by name, which is version order
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		version, err := strconv.Atoi(strings.SplitN(entry.Name(), "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("migration %s has no version prefix", entry.Name())
+		}
+		script, err := migrationFiles.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return err
+		}
+		if err := applyMigration(ctx, db, tables.migrations, version, tables.rename(string(script))); err != nil {
+			return fmt.Errorf("applying migration %s: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+// applyMigration runs script and records version in the migrations table in
+// one transaction, unless version has already been applied
+func applyMigration(ctx context.Context, db *sql.DB, migrations string, version int, script string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+		return err
+	}
+
+	var applied bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM "+migrations+" WHERE version = $1)", version).Scan(&applied)
+	if err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO "+migrations+" (version) VALUES ($1)", version); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	slog.Info("Applied migration", "version", version)
+	return nil
+}
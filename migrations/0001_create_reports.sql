@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS reports (
+	id UUID PRIMARY KEY,
+	category VARCHAR(255),
+	name VARCHAR(255),
+	address VARCHAR(255),
+	type VARCHAR(50),
+	domain VARCHAR(255),
+	timestamp VARCHAR(50),
+	date VARCHAR(50)
+);
@@ -0,0 +1,7 @@
+-- reported_at replaces the separate date and time-of-day strings; rows
+-- scraped before it existed are backfilled from those strings
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS reported_at TIMESTAMPTZ;
+
+UPDATE reports
+	SET reported_at = (date || ' ' || timestamp)::timestamptz
+	WHERE reported_at IS NULL AND date <> '' AND timestamp <> '';
@@ -0,0 +1,15 @@
+-- The dedup key is enforced by a unique index so inserts can use ON
+-- CONFLICT; duplicates that predate the index are removed first
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE schemaname = current_schema() AND indexname = 'reports_dedup_idx') THEN
+		DELETE FROM reports a USING reports b
+			WHERE a.ctid > b.ctid
+			AND a.category = b.category
+			AND a.name = b.name
+			AND a.address = b.address
+			AND a.type = b.type
+			AND a.domain = b.domain;
+		CREATE UNIQUE INDEX reports_dedup_idx ON reports (category, name, address, type, domain);
+	END IF;
+END $$;
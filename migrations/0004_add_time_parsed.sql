@@ -0,0 +1,3 @@
+-- time_parsed is false for reports stored without a reported_at because
+-- their relative time couldn't be parsed
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS time_parsed BOOLEAN NOT NULL DEFAULT TRUE;
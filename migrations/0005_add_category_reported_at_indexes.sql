@@ -0,0 +1,7 @@
+-- Supports grouping and counting by category. The ?category= filter uses
+-- ILIKE, which can't use a plain btree index.
+CREATE INDEX IF NOT EXISTS reports_category_idx ON reports (category);
+
+-- Supports the default newest-first ordering of /reports and the
+-- ?from=/?to= range filters
+CREATE INDEX IF NOT EXISTS reports_reported_at_idx ON reports (reported_at DESC);
@@ -0,0 +1,6 @@
+-- source records which site a report was scraped from. It is part of the
+-- dedup key so the same scam reported on two sites is kept twice.
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS source VARCHAR(50) NOT NULL DEFAULT 'chainabuse';
+
+DROP INDEX IF EXISTS reports_dedup_idx;
+CREATE UNIQUE INDEX reports_dedup_idx ON reports (source, category, name, address, type, domain);
@@ -0,0 +1,27 @@
+-- address now holds the normalized address used in the dedup key, and
+-- address_raw the address as it appeared on the page. Existing rows are
+-- normalized the same way as normalizeAddress, dropping any that become
+-- duplicates of another report.
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS address_raw VARCHAR(255) NOT NULL DEFAULT '';
+UPDATE reports SET address_raw = address WHERE address_raw = '';
+
+CREATE TEMPORARY TABLE normalized_addresses ON COMMIT DROP AS
+	SELECT id, CASE WHEN a ~ '^0[xX][0-9a-fA-F]{40}$' THEN lower(a) ELSE a END AS address
+	FROM (
+		SELECT id, btrim(regexp_replace(address, '[\u200B\u200C\u200D\u2060\uFEFF]', '', 'g')) AS a
+		FROM reports
+	) stripped;
+
+DELETE FROM reports a USING reports b, normalized_addresses na, normalized_addresses nb
+	WHERE na.id = a.id AND nb.id = b.id
+	AND a.ctid > b.ctid
+	AND a.source = b.source
+	AND a.category = b.category
+	AND a.name = b.name
+	AND na.address = nb.address
+	AND a.type = b.type
+	AND a.domain = b.domain;
+
+UPDATE reports r SET address = na.address
+	FROM normalized_addresses na
+	WHERE na.id = r.id AND r.address <> na.address;
@@ -0,0 +1,4 @@
+-- type_source records whether type came from the card's chain logo or was
+-- inferred from the address format; it is empty when the type is unknown
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS type_source VARCHAR(16) NOT NULL DEFAULT '';
+UPDATE reports SET type_source = 'image' WHERE type <> '' AND type_source = '';
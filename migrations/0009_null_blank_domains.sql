@@ -0,0 +1,16 @@
+-- Reports without a domain store NULL rather than an empty or whitespace
+-- string. NULLs never conflict in a plain unique index, so the dedup index
+-- keys on the coalesced domain instead; reports that differed only in the
+-- kind of blank are removed first.
+DELETE FROM reports a USING reports b
+	WHERE a.ctid > b.ctid
+	AND btrim(a.domain) = '' AND btrim(b.domain) = ''
+	AND a.source = b.source
+	AND a.category = b.category
+	AND a.name = b.name
+	AND a.address = b.address
+	AND a.type = b.type;
+UPDATE reports SET domain = NULL WHERE btrim(domain) = '';
+
+DROP INDEX IF EXISTS reports_dedup_idx;
+CREATE UNIQUE INDEX reports_dedup_idx ON reports (source, category, name, address, type, COALESCE(domain, ''));
@@ -0,0 +1,7 @@
+-- The card HTML a report was parsed from, kept when STORE_RAW_HTML is set so
+-- reports can be re-parsed after a selector fix without crawling again
+CREATE TABLE IF NOT EXISTS report_html (
+	report_id UUID PRIMARY KEY REFERENCES reports (id) ON DELETE CASCADE,
+	html TEXT NOT NULL,
+	scraped_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
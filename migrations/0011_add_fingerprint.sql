@@ -0,0 +1,13 @@
+-- fingerprint backs the fuzzy dedup mode; see fingerprint() for its
+-- definition, which the backfill mirrors
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS fingerprint CHAR(64);
+
+UPDATE reports SET fingerprint = encode(sha256(convert_to(
+	source || chr(31)
+		|| btrim(regexp_replace(lower(COALESCE(category, '')), '\s+', ' ', 'g')) || chr(31)
+		|| CASE WHEN COALESCE(address, '') <> '' THEN address
+			ELSE btrim(regexp_replace(lower(COALESCE(name, '')), '\s+', ' ', 'g')) END,
+	'UTF8')), 'hex')
+WHERE fingerprint IS NULL;
+
+CREATE INDEX IF NOT EXISTS reports_fingerprint_idx ON reports (fingerprint);
@@ -0,0 +1,8 @@
+-- category now holds the normalized category and category_raw the text shown
+-- on the card. The dedup key uses the raw text, so changing the category map
+-- doesn't let the same card be inserted again under a new label.
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS category_raw VARCHAR(255) NOT NULL DEFAULT '';
+UPDATE reports SET category_raw = COALESCE(category, '') WHERE category_raw = '';
+
+DROP INDEX IF EXISTS reports_dedup_idx;
+CREATE UNIQUE INDEX reports_dedup_idx ON reports (source, category_raw, name, address, type, COALESCE(domain, ''));
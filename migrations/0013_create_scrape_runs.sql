@@ -0,0 +1,14 @@
+-- One row per scraping job, written when the job ends, so operators can tell
+-- whether the job is keeping up
+CREATE TABLE IF NOT EXISTS scrape_runs (
+	id BIGSERIAL PRIMARY KEY,
+	started_at TIMESTAMPTZ NOT NULL,
+	finished_at TIMESTAMPTZ NOT NULL,
+	pages_attempted INTEGER NOT NULL,
+	pages_succeeded INTEGER NOT NULL,
+	pages_failed INTEGER NOT NULL,
+	reports_inserted INTEGER NOT NULL,
+	error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS scrape_runs_started_at_idx ON scrape_runs (started_at DESC);
@@ -0,0 +1,5 @@
+-- When the scraper first stored each report, as opposed to the site's own
+-- approximate reported time
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS inserted_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS reports_inserted_at_idx ON reports (inserted_at DESC, id);
@@ -0,0 +1,7 @@
+-- 0014 stamped every existing report with the time it ran, which is also the
+-- applied_at it recorded. Replace that with the best earlier evidence of when
+-- each report was first seen: when its card HTML was stored, or else the
+-- site's reported time.
+UPDATE reports r
+SET inserted_at = COALESCE((SELECT h.scraped_at FROM report_html h WHERE h.report_id = r.id), r.reported_at, r.inserted_at)
+WHERE r.inserted_at = (SELECT applied_at FROM schema_migrations WHERE version = 14);
@@ -0,0 +1,6 @@
+-- A report seen again more than a day after its last sighting is counted as
+-- a recurrence instead of being discarded. last_seen_at is the site's
+-- reported time of the latest sighting.
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS seen_count INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMPTZ;
+UPDATE reports SET last_seen_at = reported_at WHERE last_seen_at IS NULL;
@@ -0,0 +1,5 @@
+-- Per-run card counts, to tell how much of each scrape was new and whether
+-- the site's markup broke parsing
+ALTER TABLE scrape_runs ADD COLUMN IF NOT EXISTS cards_parsed INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scrape_runs ADD COLUMN IF NOT EXISTS cards_dropped INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scrape_runs ADD COLUMN IF NOT EXISTS reports_duplicate INTEGER NOT NULL DEFAULT 0;
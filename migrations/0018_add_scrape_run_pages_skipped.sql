@@ -0,0 +1,3 @@
+-- Pages robots.txt disallowed are skipped rather than failed, so they are
+-- counted apart from pages_failed
+ALTER TABLE scrape_runs ADD COLUMN IF NOT EXISTS pages_skipped INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,165 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// openAPISpec is the hand-maintained OpenAPI document of the API. Update it
+// with every route change; checkOpenAPI warns about routes it doesn't cover.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeOpenAPI(w, openAPISpec)
+}
+
+func writeOpenAPI(w http.ResponseWriter, spec []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
+
+// openAPIPaths holds the operations of the OpenAPI document by path and
+// lowercase method
+type openAPIPaths map[string]map[string]json.RawMessage
+
+// routeOperations returns the "METHOD /path" operations router serves
+func routeOperations(router *mux.Router) ([]string, error) {
+	var ops []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			ops = append(ops, method+" "+path)
+		}
+		return nil
+	})
+	return ops, err
+}
+
+// mountedOpenAPI returns the OpenAPI document without the operations router
+// doesn't serve and without the named parameters, for routers that mount
+// only part of the API
+func mountedOpenAPI(router *mux.Router, unsupported ...string) ([]byte, error) {
+	var spec map[string]json.RawMessage
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		return nil, fmt.Errorf("parsing openapi.json: %w", err)
+	}
+	var paths openAPIPaths
+	if err := json.Unmarshal(spec["paths"], &paths); err != nil {
+		return nil, fmt.Errorf("parsing openapi.json paths: %w", err)
+	}
+
+	ops, err := routeOperations(router)
+	if err != nil {
+		return nil, err
+	}
+	mounted := map[string]bool{}
+	for _, op := range ops {
+		mounted[op] = true
+	}
+	for path, operations := range paths {
+		for method, operation := range operations {
+			if !mounted[strings.ToUpper(method)+" "+path] {
+				delete(operations, method)
+				continue
+			}
+			if operations[method], err = withoutParameters(operation, unsupported); err != nil {
+				return nil, fmt.Errorf("parsing openapi.json %s %s: %w", method, path, err)
+			}
+		}
+		if len(operations) == 0 {
+			delete(paths, path)
+		}
+	}
+
+	if spec["paths"], err = json.Marshal(paths); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(spec, "", "  ")
+}
+
+// withoutParameters removes the parameters with the given names from an
+// operation, whether they are written out or refer to components
+func withoutParameters(operation json.RawMessage, names []string) (json.RawMessage, error) {
+	if len(names) == 0 {
+		return operation, nil
+	}
+	var op map[string]json.RawMessage
+	if err := json.Unmarshal(operation, &op); err != nil {
+		return nil, err
+	}
+	if op["parameters"] == nil {
+		return operation, nil
+	}
+	var params []json.RawMessage
+	if err := json.Unmarshal(op["parameters"], &params); err != nil {
+		return nil, err
+	}
+
+	kept := []json.RawMessage{}
+	for _, param := range params {
+		var p struct {
+			Name string `json:"name"`
+			Ref  string `json:"$ref"`
+		}
+		if err := json.Unmarshal(param, &p); err != nil {
+			return nil, err
+		}
+		if !slices.Contains(names, p.Name) && !slices.Contains(names, strings.TrimPrefix(p.Ref, "#/components/parameters/")) {
+			kept = append(kept, param)
+		}
+	}
+	var err error
+	if op["parameters"], err = json.Marshal(kept); err != nil {
+		return nil, err
+	}
+	return json.Marshal(op)
+}
+
+// checkOpenAPI logs a warning for every route of router missing from the
+// OpenAPI document, and for every documented operation with no route
+func checkOpenAPI(router *mux.Router) error {
+	var spec struct {
+		Paths openAPIPaths `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		return fmt.Errorf("parsing openapi.json: %w", err)
+	}
+
+	documented := map[string]bool{}
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	ops, err := routeOperations(router)
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		if !documented[op] {
+			slog.Warn("Route missing from openapi.json", "route", op)
+		}
+		delete(documented, op)
+	}
+	for op := range documented {
+		slog.Warn("openapi.json documents a route that doesn't exist", "route", op)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/chromedp"
+)
+
+// parseProxyURL splits PROXY_URL into the --proxy-server value Chrome takes
+// and the credentials it doesn't, which are answered per tab instead
+func parseProxyURL(raw string) (string, *url.Userinfo, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid PROXY_URL: %v", err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+	case "socks5", "socks4":
+		// Chrome can't authenticate to SOCKS proxies
+		if u.User != nil {
+			return "", nil, fmt.Errorf("invalid PROXY_URL: %s proxies don't support credentials", u.Scheme)
+		}
+	default:
+		return "", nil, fmt.Errorf("invalid PROXY_URL: unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", nil, fmt.Errorf("invalid PROXY_URL %q: missing host", raw)
+	}
+	return u.Scheme + "://" + u.Host, u.User, nil
+}
+
+// proxyAuth answers the proxy's authentication challenges in the tab of ctx
+// with opts.proxyAuth. It does nothing when the proxy needs no credentials.
+func proxyAuth(opts scrapeOptions) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if opts.proxyAuth == nil {
+			return nil
+		}
+		password, _ := opts.proxyAuth.Password()
+		creds := &fetch.AuthChallengeResponse{
+			Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+			Username: opts.proxyAuth.Username(),
+			Password: password,
+		}
+
+		// Listeners must not block, so the replies are sent from goroutines
+		chromedp.ListenTarget(ctx, func(ev interface{}) {
+			switch ev := ev.(type) {
+			case *fetch.EventRequestPaused:
+				go func() {
+					if err := chromedp.Run(ctx, fetch.ContinueRequest(ev.RequestID)); err != nil {
+						slog.Warn("Error continuing request through proxy", "err", err)
+					}
+				}()
+			case *fetch.EventAuthRequired:
+				go func() {
+					if err := chromedp.Run(ctx, fetch.ContinueWithAuth(ev.RequestID, creds)); err != nil {
+						slog.Warn("Error authenticating to proxy", "err", err)
+					}
+				}()
+			}
+		})
+		return fetch.Enable().WithHandleAuthRequests(true).Do(ctx)
+	})
+}
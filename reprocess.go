@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+)
+
+// reparser is implemented by scrapers that can parse a stored report card
+type reparser interface {
+	Reparse(html string) (item, error)
+}
+
+// reprocessReports parses the stored card HTML of every report again with
+// the current selectors and updates the report's fields. The id and the
+// report time are kept, since relative times would now parse differently.
+func (a *App) reprocessReports(ctx context.Context) error {
+	opts := a.cfg.Scrape
+	for _, scraper := range a.scrapers(opts) {
+		p, ok := scraper.(reparser)
+		if !ok {
+			continue
+		}
+		if err := a.reprocessSource(ctx, scraper.Name(), p, opts); err != nil {
+			return fmt.Errorf("reprocessing %s: %w", scraper.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (a *App) reprocessSource(ctx context.Context, source string, p reparser, opts scrapeOptions) error {
+	rows, err := retryQuery(ctx, a.db, `SELECT h.report_id, h.html
+		FROM `+a.cfg.Tables.html+` h JOIN `+a.cfg.Tables.reports+` r ON r.id = h.report_id
+		WHERE r.source = $1`, source)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var updated, failed int
+	for rows.Next() {
+		var (
+			id   uuid.UUID
+			html string
+		)
+		if err := rows.Scan(&id, &html); err != nil {
+			return err
+		}
+
+		report, err := p.Reparse(html)
+		if err != nil {
+			slog.Warn("Error reparsing report", "id", id, "err", err)
+			failed++
+			continue
+		}
+		report = prepareReport(report, opts)
+
+		_, err = retryExec(ctx, a.db, `UPDATE `+a.cfg.Tables.reports+`
+			SET category = $2, category_raw = $3, name = $4, address = $5, address_raw = $6,
+				type = $7, type_source = $8, domain = $9, fingerprint = $10
+			WHERE id = $1`,
+			id, report.Category, report.CategoryRaw, report.Name, report.Address, report.AddressRaw,
+			report.Type, report.TypeSource, report.Domain, report.Fingerprint)
+		if err != nil {
+			// Most likely the corrected report duplicates another one
+			slog.Warn("Error updating reparsed report", "id", id, "err", err)
+			failed++
+			continue
+		}
+		updated++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	slog.Info("Reprocessed reports", "source", source, "updated", updated, "failed", failed)
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/temoto/robotstxt"
+)
+
+// robotsUserAgent is the product token matched against robots.txt groups
+const robotsUserAgent = "go-scraper"
+
+// errDisallowedByRobots is returned for URLs robots.txt asks us not to fetch
+var errDisallowedByRobots = errors.New("disallowed by robots.txt")
+
+// robotsTimeout bounds fetching robots.txt, which happens before every job
+const robotsTimeout = 10 * time.Second
+
+// robotsClient fetches robots.txt, giving up on a site that doesn't answer
+var robotsClient = &http.Client{Timeout: robotsTimeout}
+
+// robotsFallbackSlowdown divides the request rate of a job whose robots.txt
+// couldn't be read
+const robotsFallbackSlowdown = 4
+
+// robotsRules are the robots.txt rules of one site, fetched once per job
+type robotsRules struct {
+	data *robotstxt.RobotsData
+	// unavailable is set when robots.txt couldn't be fetched or parsed
+	unavailable bool
+}
+
+// fetchRobots reads robots.txt from the root of siteURL, sent as userAgent.
+// When it can't be fetched, fails with a server error or can't be parsed, a
+// warning is logged and the rules are marked unavailable: no path is
+// disallowed, but the job should slow down with rateLimit rather than take
+// an outage as permission.
+func fetchRobots(ctx context.Context, siteURL, userAgent string) *robotsRules {
+	robotsURL, err := url.Parse(siteURL)
+	if err != nil {
+		slog.Warn("Can't build robots.txt URL", "url", siteURL, "err", err)
+		return &robotsRules{unavailable: true}
+	}
+	robotsURL.Path = "/robots.txt"
+	robotsURL.RawQuery = ""
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL.String(), nil)
+	if err != nil {
+		slog.Warn("Error fetching robots.txt; proceeding at a reduced rate", "url", robotsURL.String(), "err", err)
+		return &robotsRules{unavailable: true}
+	}
+	req.Header.Set("User-Agent", userAgent)
+	res, err := robotsClient.Do(req)
+	if err != nil {
+		slog.Warn("Error fetching robots.txt; proceeding at a reduced rate", "url", robotsURL.String(), "err", err)
+		return &robotsRules{unavailable: true}
+	}
+	defer res.Body.Close()
+
+	// FromResponse would disallow everything on a 5xx, which is as likely
+	// to be a passing outage as a real ban
+	if res.StatusCode >= http.StatusInternalServerError {
+		slog.Warn("Error fetching robots.txt; proceeding at a reduced rate", "url", robotsURL.String(), "status", res.StatusCode)
+		return &robotsRules{unavailable: true}
+	}
+
+	// FromResponse allows everything on a 4xx
+	data, err := robotstxt.FromResponse(res)
+	if err != nil {
+		slog.Warn("Error parsing robots.txt; proceeding at a reduced rate", "url", robotsURL.String(), "err", err)
+		return &robotsRules{unavailable: true}
+	}
+	return &robotsRules{data: data}
+}
+
+// rateLimit returns the requests per second to allow under the rules, given
+// the configured rate
+func (r *robotsRules) rateLimit(requestRate float64) float64 {
+	if r != nil && r.unavailable {
+		return requestRate / robotsFallbackSlowdown
+	}
+	return requestRate
+}
+
+// check returns errDisallowedByRobots when the rules disallow rawURL
+func (r *robotsRules) check(rawURL string) error {
+	if r == nil || r.data == nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if !r.data.TestAgent(u.RequestURI(), robotsUserAgent) {
+		return fmt.Errorf("%s: %w", rawURL, errDisallowedByRobots)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchRobots(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+	}))
+	defer srv.Close()
+
+	rules := fetchRobots(context.Background(), srv.URL+"/reports?page=2", defaultUserAgent)
+	if err := rules.check(srv.URL + "/reports"); err != nil {
+		t.Errorf("check(/reports) = %v, want allowed", err)
+	}
+	if err := rules.check(srv.URL + "/private/x"); !errors.Is(err, errDisallowedByRobots) {
+		t.Errorf("check(/private/x) = %v, want disallowed", err)
+	}
+	if got := rules.rateLimit(2); got != 2 {
+		t.Errorf("rateLimit(2) = %v, want 2", got)
+	}
+}
+
+func TestFetchRobotsUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	// An unreachable site doesn't stop the job but slows it down
+	rules := fetchRobots(context.Background(), srv.URL, defaultUserAgent)
+	if err := rules.check(srv.URL + "/reports"); err != nil {
+		t.Errorf("check = %v, want allowed", err)
+	}
+	if got, want := rules.rateLimit(2), 2.0/robotsFallbackSlowdown; got != want {
+		t.Errorf("rateLimit(2) = %v, want %v", got, want)
+	}
+}
+
+func TestFetchRobotsServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	// A server error is treated like an unreachable site, not as a ban
+	rules := fetchRobots(context.Background(), srv.URL, defaultUserAgent)
+	if err := rules.check(srv.URL + "/reports"); err != nil {
+		t.Errorf("check = %v, want allowed", err)
+	}
+	if got, want := rules.rateLimit(2), 2.0/robotsFallbackSlowdown; got != want {
+		t.Errorf("rateLimit(2) = %v, want %v", got, want)
+	}
+}
+
+func TestDisallowedPageIsSkipped(t *testing.T) {
+	run := newScrapeRun()
+	run.pageDone(nil)
+	run.pageDone(errors.New("timed out"))
+	run.pageDone(fmt.Errorf("https://example.com/private: %w", errDisallowedByRobots))
+	if run.PagesAttempted != 3 || run.PagesSucceeded != 1 || run.PagesFailed != 1 || run.PagesSkipped != 1 {
+		t.Errorf("run = %d attempted, %d succeeded, %d failed, %d skipped, want 3, 1, 1, 1",
+			run.PagesAttempted, run.PagesSucceeded, run.PagesFailed, run.PagesSkipped)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// recordRunTimeout bounds writing a run's row, which happens even after the
+// job's context is cancelled
+const recordRunTimeout = 10 * time.Second
+
+// scrapeRun counts the pages and reports of one scraping job. Scrapers update
+// it from several workers at once.
+type scrapeRun struct {
+	mu             sync.Mutex
+	StartedAt      time.Time `json:"started_at"`
+	FinishedAt     time.Time `json:"finished_at"`
+	PagesAttempted int       `json:"pages_attempted"`
+	PagesSucceeded int       `json:"pages_succeeded"`
+	PagesFailed    int       `json:"pages_failed"`
+	// PagesSkipped counts the pages robots.txt disallowed, which aren't failures
+	PagesSkipped int `json:"pages_skipped"`
+	// CardsParsed counts the cards of stored pages, CardsDropped those of
+	// them that couldn't be parsed into a report
+	CardsParsed  int `json:"cards_parsed"`
+	CardsDropped int `json:"cards_dropped"`
+	// ReportsInserted and ReportsDuplicate split the reports stored into new
+	// ones and those already known
+	ReportsInserted  int     `json:"reports_inserted"`
+	ReportsDuplicate int     `json:"reports_duplicate"`
+	Error            *string `json:"error"`
+}
+
+func newScrapeRun() *scrapeRun {
+	return &scrapeRun{StartedAt: time.Now()}
+}
+
+// pageDone counts one scraped page as succeeded, skipped or failed depending
+// on err
+func (r *scrapeRun) pageDone(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.PagesAttempted++
+	if errors.Is(err, errDisallowedByRobots) {
+		r.PagesSkipped++
+	} else if err != nil {
+		r.PagesFailed++
+	} else {
+		r.PagesSucceeded++
+	}
+}
+
+// cardsDone counts the cards of a stored page, dropped of which weren't stored
+func (r *scrapeRun) cardsDone(parsed, dropped int) {
+	cardsParsed.Add(float64(parsed))
+	cardsDropped.Add(float64(dropped))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.CardsParsed += parsed
+	r.CardsDropped += dropped
+}
+
+// addSaved counts a stored batch of reports, inserted of which were new
+func (r *scrapeRun) addSaved(reports, inserted int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ReportsInserted += inserted
+	r.ReportsDuplicate += reports - inserted
+}
+
+// logSummary logs the run's counts, warning when every card was dropped
+func (r *scrapeRun) logSummary() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	slog.Info("Scrape run summary", "pages", r.PagesAttempted, "pages_failed", r.PagesFailed, "pages_skipped", r.PagesSkipped,
+		"cards_parsed", r.CardsParsed, "new", r.ReportsInserted, "duplicates", r.ReportsDuplicate, "dropped", r.CardsDropped,
+		"duration", time.Since(r.StartedAt).Round(time.Second))
+	if r.CardsParsed > 0 && r.CardsDropped == r.CardsParsed {
+		slog.Warn("Every report card was dropped: the site's markup may have changed", "cards", r.CardsParsed)
+	}
+}
+
+// record marks the run finished with err and saves it to the runs table of
+// tables in db
+func (r *scrapeRun) record(ctx context.Context, db *sql.DB, tables tableNames, err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.FinishedAt = time.Now()
+	if err != nil {
+		msg := err.Error()
+		r.Error = &msg
+	}
+
+	// Record cancelled runs too, since those are the ones worth looking into
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordRunTimeout)
+	defer cancel()
+	return withDBRetry(ctx, func() error {
+		_, err := db.ExecContext(ctx, `INSERT INTO `+tables.runs+` (started_at, finished_at, pages_attempted, pages_succeeded, pages_failed,
+			pages_skipped, cards_parsed, cards_dropped, reports_inserted, reports_duplicate, error)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			r.StartedAt, r.FinishedAt, r.PagesAttempted, r.PagesSucceeded, r.PagesFailed,
+			r.PagesSkipped, r.CardsParsed, r.CardsDropped, r.ReportsInserted, r.ReportsDuplicate, r.Error)
+		return err
+	})
+}
+
+func (a *App) getLastRun(w http.ResponseWriter, r *http.Request) {
+	var run scrapeRun
+	err := retryQueryRow(r.Context(), a.db, `SELECT started_at, finished_at, pages_attempted, pages_succeeded, pages_failed,
+		pages_skipped, cards_parsed, cards_dropped, reports_inserted, reports_duplicate, error
+		FROM `+a.cfg.Tables.runs+` ORDER BY started_at DESC LIMIT 1`, nil,
+		&run.StartedAt, &run.FinishedAt, &run.PagesAttempted, &run.PagesSucceeded, &run.PagesFailed,
+		&run.PagesSkipped, &run.CardsParsed, &run.CardsDropped, &run.ReportsInserted, &run.ReportsDuplicate, &run.Error)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, r, http.StatusNotFound, "No scrape has finished yet")
+		return
+	}
+	if err != nil {
+		slog.Error("Error querying last scrape run", "err", err)
+		writeError(w, r, http.StatusInternalServerError, "Failed to fetch last scrape run")
+		return
+	}
+	writeJSON(w, http.StatusOK, &run)
+}
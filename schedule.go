@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/robfig/cron/v3"
+)
+
+// defaultScrapeSchedule starts a scrape every 15 minutes
+const defaultScrapeSchedule = "*/15 * * * *"
+
+// loadSchedule reads SCRAPE_SCHEDULE, a five-field cron expression or a
+// descriptor such as @hourly or @every 30m, in local time unless prefixed
+// with CRON_TZ=<zone>. SCRAPE_INTERVAL, which it replaces, still works as
+// @every <interval>.
+func loadSchedule() (string, cron.Schedule, error) {
+	spec := os.Getenv("SCRAPE_SCHEDULE")
+	if os.Getenv("SCRAPE_INTERVAL") != "" {
+		if spec != "" {
+			return "", nil, errors.New("SCRAPE_SCHEDULE and SCRAPE_INTERVAL can't both be set: use SCRAPE_SCHEDULE")
+		}
+		interval, err := envDuration("SCRAPE_INTERVAL", 0)
+		if err != nil {
+			return "", nil, err
+		}
+		spec = "@every " + interval.String()
+	}
+	if spec == "" {
+		spec = defaultScrapeSchedule
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid SCRAPE_SCHEDULE %q: %v", spec, err)
+	}
+	return spec, schedule, nil
+}
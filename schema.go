@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// schemaNamePattern limits DB_SCHEMA to plain lowercase identifiers, which
+// need no quoting in search_path
+var schemaNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// dbSchema reads DB_SCHEMA, the Postgres schema holding the service's tables.
+// Unset, they live in the default schema, normally public. Separate instances
+// sharing a database can each have their own schema, or their own
+// REPORTS_TABLE in a shared one.
+func dbSchema() (string, error) {
+	schema := os.Getenv("DB_SCHEMA")
+	if schema != "" && !schemaNamePattern.MatchString(schema) {
+		return "", fmt.Errorf("invalid DB_SCHEMA %q: must be a lowercase identifier", schema)
+	}
+	return schema, nil
+}
+
+// withSearchPath sets the search_path of the connection string dsn, in
+// either URL or keyword/value form, to schema alone. With public also on the
+// path, unqualified names missing from schema would resolve to public's, so
+// a migration's DROP INDEX IF EXISTS could drop another instance's index.
+func withSearchPath(dsn, schema string) (string, error) {
+	if !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") {
+		return dsn + " search_path=" + schema, nil
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", fmt.Errorf("parsing DATABASE_URL: %w", err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// createSchema creates schema unless it exists, before migrations create
+// their tables in it
+func createSchema(ctx context.Context, db *sql.DB, schema string) error {
+	if _, err := db.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+pq.QuoteIdentifier(schema)); err != nil {
+		return fmt.Errorf("creating schema %s: %w", schema, err)
+	}
+	return nil
+}
+
+// defaultReportsTable is the reports table unless REPORTS_TABLE is set
+const defaultReportsTable = "reports"
+
+// tableNamePattern limits REPORTS_TABLE to lowercase identifiers short enough
+// for the longest name derived from it, <table>_runs_started_at_idx, to fit
+// in the 63 bytes Postgres keeps
+var tableNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,42}$`)
+
+// tableNames are the quoted names of the service's Postgres tables. The
+// default reports table keeps the historical names; any other <table> has
+// <table>_html, <table>_runs and <table>_migrations beside it, so instances
+// sharing a schema share neither card HTML, runs nor applied migrations.
+type tableNames struct {
+	// name is the unquoted reports table name the others derive from
+	name       string
+	reports    string
+	html       string
+	runs       string
+	migrations string
+}
+
+var defaultTableNames = newTableNames(defaultReportsTable)
+
+func newTableNames(name string) tableNames {
+	if name == defaultReportsTable {
+		return tableNames{
+			name:       name,
+			reports:    pq.QuoteIdentifier(name),
+			html:       pq.QuoteIdentifier("report_html"),
+			runs:       pq.QuoteIdentifier("scrape_runs"),
+			migrations: pq.QuoteIdentifier("schema_migrations"),
+		}
+	}
+	return tableNames{
+		name:       name,
+		reports:    pq.QuoteIdentifier(name),
+		html:       pq.QuoteIdentifier(name + "_html"),
+		runs:       pq.QuoteIdentifier(name + "_runs"),
+		migrations: pq.QuoteIdentifier(name + "_migrations"),
+	}
+}
+
+// loadTableNames reads REPORTS_TABLE
+func loadTableNames() (tableNames, error) {
+	name := os.Getenv("REPORTS_TABLE")
+	if name == "" {
+		return defaultTableNames, nil
+	}
+	if !tableNamePattern.MatchString(name) {
+		return tableNames{}, fmt.Errorf("invalid REPORTS_TABLE %q: must be a lowercase identifier of at most 43 characters", name)
+	}
+	return newTableNames(name), nil
+}
+
+// migrationNames matches the table and index names written in migrations,
+// along with any quotes making an index name a string literal
+var migrationNames = regexp.MustCompile(`('?)\b(reports|report_html|scrape_runs|schema_migrations)((?:_[a-z]+)*_idx)?\b('?)`)
+
+// rename rewrites the table and index names of a migration script for t.
+// Index names take the prefix of their table, reports_dedup_idx becoming
+// <table>_dedup_idx, since they must be unique within the schema.
+func (t tableNames) rename(script string) string {
+	if t.name == defaultReportsTable {
+		return script
+	}
+	prefixes := map[string]string{
+		"reports":           t.name,
+		"report_html":       t.name + "_html",
+		"scrape_runs":       t.name + "_runs",
+		"schema_migrations": t.name + "_migrations",
+	}
+	return migrationNames.ReplaceAllStringFunc(script, func(match string) string {
+		m := migrationNames.FindStringSubmatch(match)
+		name := prefixes[m[2]] + m[3]
+		// A literal is compared with the unquoted names in pg_indexes
+		if m[1] != "" && m[4] != "" {
+			return "'" + name + "'"
+		}
+		return m[1] + pq.QuoteIdentifier(name) + m[4]
+	})
+}
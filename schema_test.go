@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWithSearchPath(t *testing.T) {
+	tests := []struct {
+		dsn, want string
+	}{
+		{"postgres://u:p@db:5432/scraper?sslmode=disable", "postgres://u:p@db:5432/scraper?search_path=tenant_a&sslmode=disable"},
+		{"host=db dbname=scraper", "host=db dbname=scraper search_path=tenant_a"},
+	}
+	for _, tt := range tests {
+		got, err := withSearchPath(tt.dsn, "tenant_a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// public must stay off the path, or unqualified DROPs could reach it
+		if got != tt.want {
+			t.Errorf("withSearchPath(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
+
+func TestLoadTableNames(t *testing.T) {
+	tests := []struct {
+		env     string
+		want    tableNames
+		wantErr bool
+	}{
+		{"", defaultTableNames, false},
+		{"reports", defaultTableNames, false},
+		{"order", tableNames{name: "order", reports: `"order"`, html: `"order_html"`, runs: `"order_runs"`, migrations: `"order_migrations"`}, false},
+		{"Reports", tableNames{}, true},
+		{"reports; DROP TABLE reports", tableNames{}, true},
+		{strings.Repeat("r", 44), tableNames{}, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("REPORTS_TABLE", tt.env)
+		got, err := loadTableNames()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("REPORTS_TABLE=%q: got %+v, %v", tt.env, got, err)
+		}
+	}
+}
+
+func TestRenameMigration(t *testing.T) {
+	script := `IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'reports_dedup_idx') THEN
+DELETE FROM reports a USING reports b WHERE a.ctid > b.ctid;
+DROP INDEX IF EXISTS reports_dedup_idx;
+CREATE UNIQUE INDEX reports_dedup_idx ON reports (source, category_raw);
+CREATE TABLE IF NOT EXISTS report_html (report_id UUID REFERENCES reports (id));
+CREATE INDEX IF NOT EXISTS scrape_runs_started_at_idx ON scrape_runs (started_at DESC);
+ALTER TABLE scrape_runs ADD COLUMN IF NOT EXISTS reports_inserted INTEGER;
+SELECT applied_at FROM schema_migrations WHERE version = 14;`
+	want := `IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'test_dedup_idx') THEN
+DELETE FROM "test" a USING "test" b WHERE a.ctid > b.ctid;
+DROP INDEX IF EXISTS "test_dedup_idx";
+CREATE UNIQUE INDEX "test_dedup_idx" ON "test" (source, category_raw);
+CREATE TABLE IF NOT EXISTS "test_html" (report_id UUID REFERENCES "test" (id));
+CREATE INDEX IF NOT EXISTS "test_runs_started_at_idx" ON "test_runs" (started_at DESC);
+ALTER TABLE "test_runs" ADD COLUMN IF NOT EXISTS reports_inserted INTEGER;
+SELECT applied_at FROM "test_migrations" WHERE version = 14;`
+
+	if got := newTableNames("test").rename(script); got != want {
+		t.Errorf("rename =\n%s\nwant\n%s", got, want)
+	}
+	if got := defaultTableNames.rename(script); got != script {
+		t.Errorf("default names rewrote the script:\n%s", got)
+	}
+}
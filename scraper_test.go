@@ -0,0 +1,195 @@
+package main
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+func TestPageCount(t *testing.T) {
+	tests := []struct {
+		total, want int
+	}{
+		{0, 0},
+		{14, 1},
+		{15, 1},
+		{16, 2},
+		{30, 2},
+	}
+	for _, tt := range tests {
+		if got := pageCount(tt.total, 15); got != tt.want {
+			t.Errorf("pageCount(%d, 15) = %d, want %d", tt.total, got, tt.want)
+		}
+	}
+}
+
+// checkParseTime checks that parseTime dates relative back from now as want
+// does, allowing for the clock moving on while it runs
+func checkParseTime(t *testing.T, relative string, want func(time.Time) time.Time) {
+	t.Helper()
+	before := want(time.Now()).Truncate(time.Second)
+	v := parseTime(relative)
+	after := want(time.Now())
+	got, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		t.Fatalf("parseTime(%q) = %q: %v", relative, v, err)
+	}
+	if got.Before(before) || got.After(after) {
+		t.Errorf("parseTime(%q) = %s, want between %s and %s", relative, v, before.Format(time.RFC3339), after.Format(time.RFC3339))
+	}
+}
+
+func TestParseTimeUnits(t *testing.T) {
+	tests := []struct {
+		relative string
+		want     func(time.Time) time.Time
+	}{
+		{"30 seconds ago", func(now time.Time) time.Time { return now.Add(-30 * time.Second) }},
+		{"5 minutes ago", func(now time.Time) time.Time { return now.Add(-5 * time.Minute) }},
+		{"2 hours ago", func(now time.Time) time.Time { return now.Add(-2 * time.Hour) }},
+		{"1 day ago", func(now time.Time) time.Time { return now.AddDate(0, 0, -1) }},
+		{"3 days ago", func(now time.Time) time.Time { return now.AddDate(0, 0, -3) }},
+		{"2 weeks ago", func(now time.Time) time.Time { return now.AddDate(0, 0, -14) }},
+		{"4 months ago", func(now time.Time) time.Time { return now.AddDate(0, -4, 0) }},
+		{"1 year ago", func(now time.Time) time.Time { return now.AddDate(-1, 0, 0) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.relative, func(t *testing.T) {
+			checkParseTime(t, tt.relative, tt.want)
+		})
+	}
+}
+
+func TestParseTimeInvalid(t *testing.T) {
+	for _, relative := range []string{"", "ago", "some days ago", "3 fortnights ago"} {
+		if got := parseTime(relative); got != "" {
+			t.Errorf("parseTime(%q) = %q, want empty", relative, got)
+		}
+	}
+}
+
+func TestParseTimeSingular(t *testing.T) {
+	tests := []struct {
+		relative string
+		want     func(time.Time) time.Time
+	}{
+		{"a minute ago", func(now time.Time) time.Time { return now.Add(-time.Minute) }},
+		{"an hour ago", func(now time.Time) time.Time { return now.Add(-time.Hour) }},
+		{"a day ago", func(now time.Time) time.Time { return now.AddDate(0, 0, -1) }},
+		{"A Month Ago", func(now time.Time) time.Time { return now.AddDate(0, -1, 0) }},
+		{"just now", func(now time.Time) time.Time { return now }},
+		{" Just now ", func(now time.Time) time.Time { return now }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.relative, func(t *testing.T) {
+			checkParseTime(t, tt.relative, tt.want)
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly", 7, "exactly"},
+		{"abcdef", 3, "abc"},
+		// Slicing the bytes at 4 would split the second "é" in half
+		{"éééé", 3, "ééé"},
+		{"日本語テキスト", 2, "日本"},
+	}
+	for _, tt := range tests {
+		got := truncate(tt.s, tt.max)
+		if got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d) = %q is not valid UTF-8", tt.s, tt.max, got)
+		}
+	}
+}
+
+// testScrapeOptions returns the options prepareReport needs
+func testScrapeOptions(t *testing.T) scrapeOptions {
+	t.Helper()
+	categories, err := loadCategoryNormalizer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scrapeOptions{
+		maxFieldLength:      defaultMaxFieldLength,
+		dedupMode:           dedupExact,
+		categories:          categories,
+		chainabuseSelectors: defaultChainabuseSelectors,
+	}
+}
+
+func TestNullIfBlank(t *testing.T) {
+	for _, s := range []string{"", "   ", " \n\t "} {
+		if got := nullIfBlank(s); got != nil {
+			t.Errorf("nullIfBlank(%q) = %q, want nil", s, *got)
+		}
+	}
+	if got := nullIfBlank("  example.com "); got == nil || *got != "example.com" {
+		t.Errorf("nullIfBlank kept %v, want example.com", got)
+	}
+}
+
+func TestParseReportFilterEscapesCategory(t *testing.T) {
+	r := httptest.NewRequest("GET", "/reports?category=100%25_off,Phishing", nil)
+	filter, err := parseReportFilter(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := filter.where(), " WHERE category ILIKE ANY($1)"; got != want {
+		t.Errorf("where = %q, want %q", got, want)
+	}
+	got, ok := filter.args[0].(*pq.StringArray)
+	if !ok {
+		t.Fatalf("arg = %T, want *pq.StringArray", filter.args[0])
+	}
+	if want := (pq.StringArray{`100\%\_off`, "Phishing"}); !slices.Equal(*got, want) {
+		t.Errorf("categories = %q, want %q", *got, want)
+	}
+}
+
+func TestCSVRecordEscapesFormulas(t *testing.T) {
+	report := testReport()
+	report.Name = "=HYPERLINK(\"http://evil.example\")"
+	report.AddressRaw = "+1 555"
+	domain := "@evil.example"
+	report.Domain = &domain
+
+	record := csvRecord(report)
+	for i, want := range map[int]string{3: "'" + report.Name, 4: report.Address, 5: "'+1 555", 8: "'@evil.example"} {
+		if record[i] != want {
+			t.Errorf("%s = %q, want %q", csvHeader[i], record[i], want)
+		}
+	}
+}
+
+func TestValueCountsCacheInvalidate(t *testing.T) {
+	c := newValueCountsCache()
+	_, _, generation := c.get("category")
+	c.put("category", []valueCount{{Value: "Phishing", Count: 1}}, generation)
+	if counts, ok, _ := c.get("category"); !ok || len(counts) != 1 {
+		t.Fatalf("get = %v, %v, want the stored counts", counts, ok)
+	}
+
+	// A query that started before a write must not cache its result
+	_, _, stale := c.get("type")
+	c.invalidate()
+	c.put("type", []valueCount{{Value: "ETH", Count: 1}}, stale)
+	if _, ok, _ := c.get("category"); ok {
+		t.Error("category counts survived invalidate")
+	}
+	if _, ok, _ := c.get("type"); ok {
+		t.Error("type counts from before invalidate were cached")
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteSchema is the SQLite counterpart of the migrated Postgres reports
+// table. Times are stored in UTC, so that they sort as text.
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS reports (
+	id TEXT PRIMARY KEY,
+	category TEXT NOT NULL,
+	category_raw TEXT NOT NULL,
+	name TEXT NOT NULL,
+	address TEXT NOT NULL,
+	address_raw TEXT NOT NULL,
+	type TEXT NOT NULL,
+	type_source TEXT NOT NULL,
+	domain TEXT,
+	timestamp TEXT NOT NULL,
+	date TEXT NOT NULL,
+	reported_at TIMESTAMP,
+	time_parsed BOOLEAN NOT NULL,
+	source TEXT NOT NULL,
+	fingerprint TEXT NOT NULL,
+	inserted_at TIMESTAMP NOT NULL,
+	seen_count INTEGER NOT NULL DEFAULT 1,
+	last_seen_at TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS reports_dedup ON reports (source, category_raw, name, address, type, COALESCE(domain, ''));
+CREATE INDEX IF NOT EXISTS reports_fingerprint ON reports (fingerprint);
+CREATE INDEX IF NOT EXISTS reports_reported_at ON reports (reported_at);`
+
+// sqliteStore keeps reports in a SQLite database file. It doesn't count
+// recurrences or keep card HTML.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func openSQLiteStore(ctx context.Context, path string) (*sqliteStore, error) {
+	// The busy timeout makes a second connection wait for a writer instead
+	// of failing with "database is locked"
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating SQLite schema in %s: %w", path, err)
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) Save(ctx context.Context, reports []item, opts scrapeOptions) ([]item, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	var inserted []item
+	for _, report := range reports {
+		if opts.dedupMode == dedupFuzzy {
+			var known bool
+			err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM reports WHERE fingerprint = ?)", report.Fingerprint).Scan(&known)
+			if err != nil {
+				return nil, fmt.Errorf("checking fingerprint: %w", err)
+			}
+			if known {
+				continue
+			}
+		}
+
+		var reportedAt *time.Time
+		if report.ReportedAt != nil {
+			t := report.ReportedAt.UTC()
+			reportedAt = &t
+		}
+		res, err := tx.ExecContext(ctx, `INSERT INTO reports (id, category, category_raw, name, address, address_raw, type, type_source, domain,
+			timestamp, date, reported_at, time_parsed, source, fingerprint, inserted_at, last_seen_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+			report.ID.String(), report.Category, report.CategoryRaw, report.Name, report.Address, report.AddressRaw, report.Type,
+			report.TypeSource, report.Domain, report.Timestamp, report.Date, reportedAt, report.TimeParsed, report.Source,
+			report.Fingerprint, now, reportedAt)
+		if err != nil {
+			return nil, fmt.Errorf("inserting report: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+		report.InsertedAt = now
+		report.SeenCount = 1
+		report.LastSeenAt = reportedAt
+		inserted = append(inserted, report)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("inserting reports: %w", err)
+	}
+	reportsSkipped.Add(float64(len(reports) - len(inserted)))
+	return inserted, nil
+}
+
+func (s *sqliteStore) Exists(ctx context.Context, report item) (bool, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM reports
+		WHERE source = ? AND category_raw = ? AND name = ? AND address = ? AND type = ? AND COALESCE(domain, '') = COALESCE(?, ''))`,
+		report.Source, report.CategoryRaw, report.Name, report.Address, report.Type, report.Domain).Scan(&exists)
+	return exists, err
+}
+
+func (s *sqliteStore) List(ctx context.Context, query reportQuery, page, limit int) (reportsPage, error) {
+	result := reportsPage{Page: page, Limit: limit, Data: []item{}}
+	if !query.defaultOrder() {
+		return result, errUnsupportedQuery
+	}
+	var err error
+	if result.Total, err = s.Count(ctx, query); err != nil {
+		return result, err
+	}
+
+	conditions, args := sqliteConditions(query)
+	offset := (page - 1) * limit
+	switch c := query.after; {
+	case c == nil:
+	case c.ReportedAt == nil:
+		conditions = append(conditions, "(reported_at IS NULL AND id > ?)")
+		args, offset = append(args, c.ID.String()), 0
+	default:
+		conditions = append(conditions, "(reported_at < ? OR (reported_at = ? AND id > ?) OR reported_at IS NULL)")
+		args, offset = append(args, c.ReportedAt.UTC(), c.ReportedAt.UTC(), c.ID.String()), 0
+	}
+	rows, err := s.db.QueryContext(ctx, "SELECT "+reportColumns+" FROM reports"+sqliteWhere(conditions)+" ORDER BY "+defaultReportOrder+" LIMIT ? OFFSET ?",
+		append(args, limit, offset)...)
+	if err != nil {
+		return result, fmt.Errorf("querying reports: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return result, fmt.Errorf("scanning report: %w", err)
+		}
+		result.Data = append(result.Data, report)
+	}
+	return result, rows.Err()
+}
+
+func (s *sqliteStore) Count(ctx context.Context, query reportQuery) (int, error) {
+	conditions, args := sqliteConditions(query)
+	var count int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM reports"+sqliteWhere(conditions), args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("counting reports: %w", err)
+	}
+	return count, nil
+}
+
+// sqliteConditions is reportQuery.filter for SQLite, whose NOCASE collation
+// stands in for ILIKE without wildcards
+func sqliteConditions(query reportQuery) ([]string, []interface{}) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	in := func(column, collate string, values []string) string {
+		for _, v := range values {
+			args = append(args, v)
+		}
+		return column + collate + " IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ") + ")"
+	}
+
+	if len(query.categories) > 0 {
+		conditions = append(conditions, in("category", " COLLATE NOCASE", query.categories))
+	}
+	if len(query.sources) > 0 {
+		conditions = append(conditions, in("source", "", query.sources))
+	}
+	switch {
+	case query.unknownType && len(query.types) > 0:
+		conditions = append(conditions, "("+in("type", " COLLATE NOCASE", query.types)+" OR type = '')")
+	case query.unknownType:
+		conditions = append(conditions, "type = ''")
+	case len(query.types) > 0:
+		conditions = append(conditions, in("type", " COLLATE NOCASE", query.types))
+	}
+	if !query.from.IsZero() {
+		conditions = append(conditions, "reported_at >= ?")
+		args = append(args, query.from.UTC())
+	}
+	if !query.to.IsZero() {
+		conditions = append(conditions, "reported_at < ?")
+		args = append(args, query.to.UTC())
+	}
+
+	return conditions, args
+}
+
+func sqliteWhere(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+func (s *sqliteStore) Get(ctx context.Context, id uuid.UUID) (item, error) {
+	report, err := scanReport(s.db.QueryRowContext(ctx, "SELECT "+reportColumns+" FROM reports WHERE id = ?", id.String()))
+	if err == sql.ErrNoRows {
+		return report, errReportNotFound
+	}
+	return report, err
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
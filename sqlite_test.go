@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func openTestSQLiteStore(t *testing.T) *sqliteStore {
+	t.Helper()
+	s, err := openSQLiteStore(context.Background(), filepath.Join(t.TempDir(), "reports.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLiteStoreDedup(t *testing.T) {
+	ctx := context.Background()
+	opts := testScrapeOptions(t)
+	s := openTestSQLiteStore(t)
+
+	report := testReport()
+	inserted, err := s.Save(ctx, prepareBatch([]item{report}, opts), opts)
+	if err != nil || len(inserted) != 1 {
+		t.Fatalf("first save inserted %d reports, err %v; want 1", len(inserted), err)
+	}
+	if exists, err := s.Exists(ctx, inserted[0]); err != nil || !exists {
+		t.Errorf("Exists = %v, %v after saving, want true", exists, err)
+	}
+	// The same report scraped again is already stored
+	again := report
+	again.ID = uuid.New()
+	inserted, err = s.Save(ctx, prepareBatch([]item{again}, opts), opts)
+	if err != nil || len(inserted) != 0 {
+		t.Fatalf("second save inserted %d reports, err %v; want 0", len(inserted), err)
+	}
+	if count, err := s.Count(ctx, reportQuery{}); err != nil || count != 1 {
+		t.Errorf("Count = %d, %v, want 1", count, err)
+	}
+}
+
+func TestSQLiteStoreListGet(t *testing.T) {
+	ctx := context.Background()
+	opts := testScrapeOptions(t)
+	s := openTestSQLiteStore(t)
+
+	report := testReport()
+	other := report
+	other.ID, other.Name, other.Type = uuid.New(), "bob", "BTC"
+	if _, err := s.Save(ctx, prepareBatch([]item{report, other}, opts), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.List(ctx, reportQuery{types: []string{"eth"}}, 1, defaultPageLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 1 || len(result.Data) != 1 || result.Data[0].ID != report.ID {
+		t.Fatalf("List(type=eth) = %+v, want only %s", result, report.ID)
+	}
+	listed := result.Data[0]
+	if listed.Name != report.Name || listed.Address != report.Address || !listed.ReportedAt.Equal(*report.ReportedAt) {
+		t.Errorf("listed report = %+v, want %+v", listed, report)
+	}
+
+	// Times are compared as text, in UTC
+	day := *report.ReportedAt
+	for _, q := range []reportQuery{{from: day, to: day.AddDate(0, 0, 1)}, {to: day.AddDate(0, 0, 1)}} {
+		if count, err := s.Count(ctx, q); err != nil || count != 2 {
+			t.Errorf("Count(%v to %v) = %d, %v, want 2", q.from, q.to, count, err)
+		}
+	}
+	if count, err := s.Count(ctx, reportQuery{to: day}); err != nil || count != 0 {
+		t.Errorf("Count(before %v) = %d, %v, want 0", day, count, err)
+	}
+
+	// The cursor continues after the first report in the default order
+	first, err := s.List(ctx, reportQuery{}, 1, 1)
+	if err != nil || len(first.Data) != 1 {
+		t.Fatalf("first page = %+v, %v", first, err)
+	}
+	next, err := s.List(ctx, reportQuery{after: &reportCursor{ReportedAt: first.Data[0].ReportedAt, ID: first.Data[0].ID}}, 1, 1)
+	if err != nil || len(next.Data) != 1 || next.Data[0].ID == first.Data[0].ID {
+		t.Errorf("page after %s = %+v, %v, want the other report", first.Data[0].ID, next, err)
+	}
+
+	got, err := s.Get(ctx, other.ID)
+	if err != nil || got.Name != "bob" {
+		t.Errorf("Get = %+v, %v, want bob", got, err)
+	}
+	if _, err := s.Get(ctx, uuid.New()); !errors.Is(err, errReportNotFound) {
+		t.Errorf("Get of an unknown id = %v, want errReportNotFound", err)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Store keeps the scraped reports. Postgres backs the whole API; the SQLite,
+// JSON Lines and memory stores need no database server, and serve listing,
+// filtering and counting reports in defaultReportOrder and fetching them by
+// id. Exports, the statistics and grouping endpoints, PATCH and DELETE query
+// the reports table directly and need Postgres.
+type Store interface {
+	// Save stores the reports that aren't stored yet, all of them or none,
+	// and returns those it stored. The reports come from prepareBatch. Save
+	// doesn't call Exists: it dedups within its own write, which a check
+	// made beforehand would race with.
+	Save(ctx context.Context, reports []item, opts scrapeOptions) ([]item, error)
+	// Exists reports whether a report with the dedup key of report is stored
+	Exists(ctx context.Context, report item) (bool, error)
+	// List returns a page of the reports matching query, in its order, and
+	// their total. Stores that can only list in defaultReportOrder return
+	// errUnsupportedQuery for any other.
+	List(ctx context.Context, query reportQuery, page, limit int) (reportsPage, error)
+	// Count returns the number of reports matching the filters of query
+	Count(ctx context.Context, query reportQuery) (int, error)
+	// Get returns the report with id, or errReportNotFound
+	Get(ctx context.Context, id uuid.UUID) (item, error)
+	Close() error
+}
+
+var errReportNotFound = errors.New("report not found")
+
+// errUnsupportedQuery is returned by the stores other than Postgres for a
+// reportQuery that isn't in defaultReportOrder
+var errUnsupportedQuery = errors.New("store only lists reports in the default order")
+
+// Store kinds selected by STORE
+const (
+	storePostgres = "postgres"
+	storeSQLite   = "sqlite"
+	storeJSONL    = "jsonl"
+	storeMemory   = "memory"
+)
+
+// Default STORE_PATH of the file-backed stores
+const (
+	defaultSQLitePath = "reports.db"
+	defaultJSONLPath  = "reports.jsonl"
+)
+
+// storeConfig selects where reports are kept
+type storeConfig struct {
+	kind string
+	// path is the SQLite database or JSON Lines file; "-" writes JSON Lines
+	// to stdout
+	path string
+}
+
+// loadStoreConfig reads STORE and STORE_PATH
+func loadStoreConfig() (storeConfig, error) {
+	v := os.Getenv("STORE")
+	cfg := storeConfig{kind: strings.ToLower(v), path: os.Getenv("STORE_PATH")}
+	switch cfg.kind {
+	case "", storePostgres:
+		cfg.kind = storePostgres
+	case storeMemory:
+	case storeSQLite:
+		if cfg.path == "" {
+			cfg.path = defaultSQLitePath
+		}
+	case storeJSONL:
+		if cfg.path == "" {
+			cfg.path = defaultJSONLPath
+		}
+	default:
+		return cfg, fmt.Errorf("invalid STORE %q: must be postgres, sqlite, jsonl or memory", v)
+	}
+	return cfg, nil
+}
+
+// openStore opens the store cfg selects. The database is only returned for
+// Postgres, and is nil otherwise.
+func openStore(ctx context.Context, cfg Config) (*sql.DB, Store, error) {
+	switch cfg.Store.kind {
+	case storeSQLite:
+		store, err := openSQLiteStore(ctx, cfg.Store.path)
+		return nil, store, err
+	case storeJSONL:
+		store, err := openJSONLStore(cfg.Store.path)
+		return nil, store, err
+	case storeMemory:
+		return nil, newMemoryStore(), nil
+	}
+	db, err := openDB(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, &postgresStore{db: db, tables: cfg.Tables}, nil
+}
+
+// prepareBatch fits reports to the store with prepareReport and drops those
+// repeating an earlier one of the batch, by dedup key or, in fuzzy mode, by
+// fingerprint. Stores can't see other rows of the batch they are saving, and
+// an upsert may not touch the same row twice.
+func prepareBatch(reports []item, opts scrapeOptions) []item {
+	var (
+		batch        = make([]item, 0, len(reports))
+		fingerprints = map[string]bool{}
+		keys         = map[string]bool{}
+	)
+	for _, report := range reports {
+		report = prepareReport(report, opts)
+		key := dedupKey(report)
+		if keys[key] || (opts.dedupMode == dedupFuzzy && fingerprints[report.Fingerprint]) {
+			reportsSkipped.Inc()
+			continue
+		}
+		keys[key] = true
+		fingerprints[report.Fingerprint] = true
+		batch = append(batch, report)
+	}
+	return batch
+}
+
+// postgresStore keeps reports in the Postgres reports table. Its Save is
+// next to insertReportsQuery.
+type postgresStore struct {
+	db     *sql.DB
+	tables tableNames
+}
+
+// The postgresStore methods retry after transient errors
+
+func (s *postgresStore) Exists(ctx context.Context, report item) (bool, error) {
+	var exists bool
+	err := withDBRetry(ctx, func() error {
+		return s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM `+s.tables.reports+`
+			WHERE source = $1 AND category_raw = $2 AND name = $3 AND address = $4 AND type = $5 AND COALESCE(domain, '') = COALESCE($6, ''))`,
+			report.Source, report.CategoryRaw, report.Name, report.Address, report.Type, report.Domain).Scan(&exists)
+	})
+	return exists, err
+}
+
+func (s *postgresStore) List(ctx context.Context, query reportQuery, page, limit int) (reportsPage, error) {
+	var result reportsPage
+	err := withDBRetry(ctx, func() error {
+		var err error
+		result, err = s.list(ctx, query, page, limit)
+		return err
+	})
+	return result, err
+}
+
+func (s *postgresStore) list(ctx context.Context, query reportQuery, page, limit int) (reportsPage, error) {
+	result := reportsPage{Page: page, Limit: limit, Data: []item{}}
+	filter := query.filter()
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+s.tables.reports+filter.where(), filter.args...).Scan(&result.Total); err != nil {
+		return result, fmt.Errorf("counting reports: %w", err)
+	}
+
+	pageFilter, offset := filter, (page-1)*limit
+	if query.after != nil {
+		pageFilter, offset = query.after.condition(filter), 0
+	}
+	orderBy, orderArgs := query.order(len(pageFilter.args))
+	args := append(append([]interface{}{}, pageFilter.args...), orderArgs...)
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s LIMIT $%d OFFSET $%d",
+		reportColumns, s.tables.reports, pageFilter.where(), orderBy, len(args)+1, len(args)+2), append(args, limit, offset)...)
+	if err != nil {
+		return result, fmt.Errorf("querying reports: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return result, fmt.Errorf("scanning report: %w", err)
+		}
+		result.Data = append(result.Data, report)
+	}
+	return result, rows.Err()
+}
+
+func (s *postgresStore) Count(ctx context.Context, query reportQuery) (int, error) {
+	filter := query.filter()
+	var count int
+	err := withDBRetry(ctx, func() error {
+		return s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+s.tables.reports+filter.where(), filter.args...).Scan(&count)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("counting reports: %w", err)
+	}
+	return count, nil
+}
+
+func (s *postgresStore) Get(ctx context.Context, id uuid.UUID) (item, error) {
+	var report item
+	err := withDBRetry(ctx, func() error {
+		var err error
+		report, err = scanReport(s.db.QueryRowContext(ctx, "SELECT "+reportColumns+" FROM "+s.tables.reports+" WHERE id = $1", id))
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return report, errReportNotFound
+	}
+	return report, err
+}
+
+func (s *postgresStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxSubscribers = 100
+	// streamKeepalive is how often an idle stream sends a comment so proxies
+	// don't close it
+	streamKeepalive = 15 * time.Second
+	// subscriberBuffer is how many reports a slow client may fall behind by
+	// before further ones are dropped for it
+	subscriberBuffer = 32
+)
+
+// reportBroker fans newly inserted reports out to the connected stream clients
+type reportBroker struct {
+	mu             sync.Mutex
+	subscribers    map[chan item]struct{}
+	maxSubscribers int
+}
+
+func newReportBroker(maxSubscribers int) *reportBroker {
+	return &reportBroker{subscribers: map[chan item]struct{}{}, maxSubscribers: maxSubscribers}
+}
+
+// subscribe registers a new client, returning false when the broker is full
+func (b *reportBroker) subscribe() (chan item, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.subscribers) >= b.maxSubscribers {
+		return nil, false
+	}
+	ch := make(chan item, subscriberBuffer)
+	b.subscribers[ch] = struct{}{}
+	return ch, true
+}
+
+func (b *reportBroker) unsubscribe(ch chan item) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, ch)
+}
+
+// publish sends report to every subscriber without waiting on slow ones
+func (b *reportBroker) publish(report item) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- report:
+		default:
+		}
+	}
+}
+
+// streamReports returns a handler pushing each new report to the client as a
+// Server-Sent Event until the client disconnects or ctx is cancelled
+func streamReports(ctx context.Context, broker *reportBroker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ch, ok := broker.subscribe()
+		if !ok {
+			writeError(w, r, http.StatusServiceUnavailable, "Too many stream subscribers")
+			return
+		}
+		defer broker.unsubscribe(ch)
+
+		rc := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		if err := rc.Flush(); err != nil {
+			slog.Warn("Streaming not supported", "err", err)
+			return
+		}
+
+		keepalive := time.NewTicker(streamKeepalive)
+		defer keepalive.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ctx.Done():
+				return
+			case <-keepalive.C:
+				if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+					return
+				}
+			case report := <-ch:
+				data, err := json.Marshal(report)
+				if err != nil {
+					slog.Error("Error encoding report", "id", report.ID, "err", err)
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "event: report\nid: %s\ndata: %s\n\n", report.ID, data); err != nil {
+					return
+				}
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxBodyBytes caps the JSON bodies the API accepts
+const maxBodyBytes = 64 << 10
+
+// fieldError reports what is wrong with one field of a request body
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// writeFieldErrors writes a 400 response listing every invalid field
+func writeFieldErrors(w http.ResponseWriter, r *http.Request, errs []fieldError) {
+	writeJSON(w, http.StatusBadRequest, apiError{
+		Error:     "Invalid request body",
+		Code:      http.StatusBadRequest,
+		RequestID: requestIDFrom(r.Context()),
+		Fields:    errs,
+	})
+}
+
+// decodeStrict decodes the single JSON object in the body of r into v,
+// rejecting unknown fields, trailing data and bodies over maxBodyBytes. Type
+// mismatches and unknown fields are returned as field errors.
+func decodeStrict(w http.ResponseWriter, r *http.Request, v interface{}) ([]fieldError, error) {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case err == nil:
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return []fieldError{{Field: typeErr.Field, Message: "must be a " + jsonTypeName(typeErr.Type.Kind().String())}}, nil
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return []fieldError{{Field: field, Message: "is not a known field"}}, nil
+	default:
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, fmt.Errorf("Body is larger than %d bytes", maxBodyBytes)
+		}
+		return nil, errors.New("Invalid JSON body")
+	}
+
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		return nil, errors.New("Body must contain a single JSON object")
+	}
+	return nil, nil
+}
+
+// jsonTypeName names a Go kind the way API clients know it
+func jsonTypeName(kind string) string {
+	switch kind {
+	case "string":
+		return "string"
+	case "bool":
+		return "boolean"
+	case "slice", "array":
+		return "array"
+	case "map", "struct":
+		return "object"
+	}
+	return "number"
+}
+
+// reportUpdate is the body of PATCH /reports/{id}. Fields left out are not
+// changed; null is only accepted for domain, which it clears. The fields are
+// kept raw so every invalid one can be reported at once.
+type reportUpdate struct {
+	Category json.RawMessage `json:"category"`
+	Name     json.RawMessage `json:"name"`
+	Address  json.RawMessage `json:"address"`
+	Type     json.RawMessage `json:"type"`
+	Domain   json.RawMessage `json:"domain"`
+}
+
+// fields returns the values of the fields u sets, keyed by their column and
+// nil when null, or an error for every field that can't be stored
+func (u reportUpdate) fields() (map[string]*string, []fieldError) {
+	raw := map[string]json.RawMessage{
+		"category": u.Category,
+		"name":     u.Name,
+		"address":  u.Address,
+		"type":     u.Type,
+		"domain":   u.Domain,
+	}
+
+	var errs []fieldError
+	fields := map[string]*string{}
+	for _, column := range editableColumns {
+		data := raw[column]
+		if data == nil {
+			continue
+		}
+		var value *string
+		if err := json.Unmarshal(data, &value); err != nil {
+			errs = append(errs, fieldError{Field: column, Message: "must be a string"})
+			continue
+		}
+		switch {
+		case value == nil && column != "domain":
+			errs = append(errs, fieldError{Field: column, Message: "must not be null"})
+		case value == nil:
+		case utf8.RuneCountInString(*value) > columnLengths[column]:
+			errs = append(errs, fieldError{Field: column, Message: fmt.Sprintf("must be at most %d characters", columnLengths[column])})
+		case column == "category" && strings.TrimSpace(*value) == "":
+			errs = append(errs, fieldError{Field: column, Message: "must not be blank"})
+		}
+		fields[column] = value
+	}
+	return fields, errs
+}
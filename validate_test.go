@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestReportUpdateFieldLengths(t *testing.T) {
+	tests := []struct {
+		column string
+		length int
+		valid  bool
+	}{
+		{"type", 50, true},
+		{"type", 51, false},
+		{"name", 255, true},
+		{"name", 256, false},
+		{"domain", 255, true},
+		{"domain", 256, false},
+	}
+	for _, tt := range tests {
+		body, _ := json.Marshal(map[string]string{tt.column: strings.Repeat("é", tt.length)})
+		var u reportUpdate
+		if err := json.Unmarshal(body, &u); err != nil {
+			t.Fatal(err)
+		}
+		_, errs := u.fields()
+		if valid := len(errs) == 0; valid != tt.valid {
+			t.Errorf("%s of %d characters: errors %v, want valid %v", tt.column, tt.length, errs, tt.valid)
+		}
+	}
+}
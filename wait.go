@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// defaultCardWaitTimeout is how long report cards may take to appear once
+// the results section of a page is ready
+const defaultCardWaitTimeout = 5 * time.Second
+
+// Wait strategies decide when a page's report cards have rendered
+const (
+	// waitVisible waits for the wait selector to match a visible element
+	waitVisible = "visible"
+	// waitReady waits for the wait selector to be in the DOM, visible or not,
+	// which suits a container that is present even when the page is empty
+	waitReady = "ready"
+	// waitCount polls until the card selector matches at least one element
+	waitCount = "count"
+)
+
+// cardPollInterval is how often the waitCount strategy counts the cards
+const cardPollInterval = 100 * time.Millisecond
+
+// parseWaitStrategy reads the named variable, defaulting to waitVisible
+func parseWaitStrategy(name string) (string, error) {
+	v := os.Getenv(name)
+	switch strategy := strings.ToLower(strings.TrimSpace(v)); strategy {
+	case "":
+		return waitVisible, nil
+	case waitVisible, waitReady, waitCount:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("invalid %s %q: must be visible, ready or count", name, v)
+}
+
+// waitForCards returns the action that waits, following strategy, until the
+// report cards sel describes have rendered
+func waitForCards(strategy string, sel selectors) chromedp.Action {
+	switch strategy {
+	case waitReady:
+		return chromedp.WaitReady(sel.wait)
+	case waitCount:
+		// Marshalling quotes the selector as a JavaScript string
+		quoted, _ := json.Marshal(sel.card)
+		expr := fmt.Sprintf("document.querySelectorAll(%s).length > 0", quoted)
+		// The caller's context bounds the wait, not the poll's own timeout
+		return chromedp.Poll(expr, nil, chromedp.WithPollingInterval(cardPollInterval), chromedp.WithPollingTimeout(0))
+	}
+	return chromedp.WaitVisible(sel.wait)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Defaults for webhook delivery
+const (
+	defaultWebhookTimeout = 5 * time.Second
+	webhookMaxAttempts    = 3
+	webhookRetryDelay     = time.Second
+	// webhookQueueSize bounds the reports waiting for delivery; newer ones are
+	// dropped rather than slowing the scrape down when the endpoint is stuck
+	webhookQueueSize = 256
+)
+
+// webhook POSTs each newly inserted report as JSON to a configured URL
+type webhook struct {
+	url    string
+	secret string
+	client *http.Client
+	queue  chan item
+}
+
+// webhookConfig is where and how new reports are POSTed
+type webhookConfig struct {
+	url     string
+	secret  string
+	timeout time.Duration
+}
+
+// loadWebhookConfig reads WEBHOOK_URL, WEBHOOK_SECRET and WEBHOOK_TIMEOUT
+func loadWebhookConfig() (webhookConfig, error) {
+	cfg := webhookConfig{url: os.Getenv("WEBHOOK_URL"), secret: os.Getenv("WEBHOOK_SECRET")}
+	var err error
+	if cfg.timeout, err = envDuration("WEBHOOK_TIMEOUT", defaultWebhookTimeout); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// newWebhook returns the webhook of cfg, or nil when no URL is set
+func newWebhook(cfg webhookConfig) *webhook {
+	if cfg.url == "" {
+		return nil
+	}
+	return &webhook{
+		url:    cfg.url,
+		secret: cfg.secret,
+		client: &http.Client{Timeout: cfg.timeout},
+		queue:  make(chan item, webhookQueueSize),
+	}
+}
+
+// notify queues report for delivery without blocking
+func (wh *webhook) notify(report item) {
+	select {
+	case wh.queue <- report:
+	default:
+		slog.Warn("Webhook queue full; dropping report", "id", report.ID)
+	}
+}
+
+// run delivers queued reports one at a time until ctx is cancelled
+func (wh *webhook) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case report := <-wh.queue:
+			if err := wh.deliver(ctx, report); err != nil {
+				slog.Warn("Error delivering webhook", "id", report.ID, "err", err)
+			}
+		}
+	}
+}
+
+// deliver POSTs report, retrying failures with a doubling delay. When a
+// secret is set the body is signed with HMAC-SHA256 in X-Signature-256.
+func (wh *webhook) deliver(ctx context.Context, report item) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	delay := webhookRetryDelay
+	for attempt := 1; ; attempt++ {
+		if err = wh.post(ctx, body); err == nil || attempt == webhookMaxAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (wh *webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if wh.secret != "" {
+		mac := hmac.New(sha256.New, []byte(wh.secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	res, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", res.Status)
+	}
+	return nil
+}